@@ -5,7 +5,6 @@ import (
 	"path/filepath"
 
 	"github.com/rs/zerolog/log"
-	win "golang.org/x/sys/windows/svc"
 
 	"github.com/edwardezs/win-svc/pkg/cli"
 	"github.com/edwardezs/win-svc/pkg/config"
@@ -14,8 +13,11 @@ import (
 
 const svcName = "Example Windows Service"
 
+// defaultLogFile is the service log written next to the service binary when the configuration can not be loaded
+const defaultLogFile = "service.log"
+
 func main() {
-	isWinSvc, err := win.IsWindowsService()
+	isWinSvc, err := service.IsService()
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to determine if application is running as Windows service")
 	}
@@ -25,17 +27,27 @@ func main() {
 		if err != nil {
 			return
 		}
-		cfgPath := filepath.Join(filepath.Dir(exePath), cli.CfgFlag.Value)
-		cfg, err := config.New(cfgPath)
+		var cfg config.WindowsServiceConfig
+		var opts []service.Option
+		cfgPath, overlays, err := cli.ServiceConfigPaths(exePath, os.Args[1:])
+		if err == nil {
+			cfg, err = config.New(cfgPath, overlays...)
+		}
 		if err != nil {
-			return
+			// the service reports the error to the service manager, logged next to the service binary
+			cfg = config.WindowsServiceConfig{Name: cfg.Name, LogFilePath: filepath.Join(filepath.Dir(exePath), defaultLogFile)}
+			opts = append(opts, service.WithConfigError(err))
 		}
-		svc := service.New(cfg)
+		svc := service.NewService(cfg, opts...)
+		svc.ConfigPath = cfgPath
+		svc.ConfigOverlays = overlays
 		svc.Run()
+		return
 	}
 
 	app := cli.New(svcName)
 	if err := app.Run(os.Args); err != nil {
 		log.Error().Err(err).Msg("An error occurred while running the application")
+		os.Exit(cli.ExitCode(err))
 	}
 }
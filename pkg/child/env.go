@@ -0,0 +1,163 @@
+package child
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const maskedValue = "****"
+
+// secretKeyParts mark environment variables whose values are masked in the service log
+var secretKeyParts = []string{"SECRET", "PASSWORD", "PASSWD", "TOKEN", "KEY", "CREDENTIAL", "AUTH"}
+
+// Env describes the environment of the child process
+type Env struct {
+	Inherit bool
+	// Files are dotenv files, relative paths are resolved against the working directory
+	Files []string
+	Vars  map[string]string
+}
+
+// Resolve builds the environment of a child process running in dir.
+// Variables from Files override the inherited ones and Vars override both.
+// It also returns the variables that were set on top of the inherited environment.
+func (e Env) Resolve(dir string) (environ, overrides []string, err error) {
+	vars := newEnvMap()
+	if e.Inherit {
+		for _, kv := range os.Environ() {
+			k, v, _ := strings.Cut(kv, "=")
+			vars.set(k, v)
+		}
+	}
+
+	added := newEnvMap()
+	for _, file := range e.Files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		fileVars, err := ReadEnvFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range fileVars {
+			vars.set(k, v)
+			added.set(k, v)
+		}
+	}
+	for k, v := range e.Vars {
+		vars.set(k, v)
+		added.set(k, v)
+	}
+
+	return vars.list(), added.list(), nil
+}
+
+// envMap keeps the case of keys while matching them case-insensitively on Windows
+type envMap struct {
+	keys   map[string]string
+	values map[string]string
+}
+
+func newEnvMap() envMap {
+	return envMap{keys: make(map[string]string), values: make(map[string]string)}
+}
+
+func (m envMap) set(k, v string) {
+	norm := k
+	if runtime.GOOS == "windows" {
+		norm = strings.ToUpper(k)
+	}
+	m.keys[norm] = k
+	m.values[norm] = v
+}
+
+func (m envMap) list() []string {
+	list := make([]string, 0, len(m.values))
+	for norm, v := range m.values {
+		list = append(list, m.keys[norm]+"="+v)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// ReadEnvFile parses a dotenv file: KEY=VALUE lines, optionally prefixed with export,
+// with single or double quoted values, blank lines and # comments
+func ReadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not open env file %s", path)
+	}
+	defer f.Close()
+
+	vars, err := ParseEnv(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not parse env file %s", path)
+	}
+
+	return vars, nil
+}
+
+// ParseEnv parses dotenv formatted variables, see ReadEnvFile
+func ParseEnv(r io.Reader) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, errors.Wrapf(ErrInvalidEnvLine, "line %d", n)
+		}
+		v = strings.TrimSpace(v)
+
+		switch {
+		case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+			unquoted, err := strconv.Unquote(v)
+			if err != nil {
+				return nil, errors.Wrapf(ErrInvalidEnvLine, "line %d", n)
+			}
+			v = unquoted
+		case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+			v = v[1 : len(v)-1]
+		default:
+			if i := strings.Index(v, " #"); i >= 0 {
+				v = strings.TrimSpace(v[:i])
+			}
+		}
+		vars[k] = v
+	}
+
+	return vars, scanner.Err()
+}
+
+// MaskEnv returns env with the values of secret-looking variables replaced
+func MaskEnv(env []string) []string {
+	masked := make([]string, len(env))
+	for i, kv := range env {
+		k, _, _ := strings.Cut(kv, "=")
+		masked[i] = kv
+		upper := strings.ToUpper(k)
+		for _, part := range secretKeyParts {
+			if strings.Contains(upper, part) {
+				masked[i] = k + "=" + maskedValue
+				break
+			}
+		}
+	}
+
+	return masked
+}
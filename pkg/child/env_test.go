@@ -0,0 +1,67 @@
+package child
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnv(t *testing.T) {
+	vars, err := ParseEnv(strings.NewReader(`
+# database
+export DB_HOST=localhost
+DB_PORT = 5432 # default port
+DB_NAME="app\tprod"
+DB_PASSWORD='p#ss word'
+EMPTY=
+`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"DB_HOST":     "localhost",
+		"DB_PORT":     "5432",
+		"DB_NAME":     "app\tprod",
+		"DB_PASSWORD": "p#ss word",
+		"EMPTY":       "",
+	}, vars)
+
+	_, err = ParseEnv(strings.NewReader("NO_VALUE\n"))
+	require.ErrorIs(t, err, ErrInvalidEnvLine)
+}
+
+func TestEnvResolve(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "base.env"), []byte("A=file\nB=file\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "local.env"), []byte("B=local\n"), 0o644))
+	t.Setenv("WINSVC_INHERITED", "yes")
+
+	env := Env{
+		Inherit: true,
+		Files:   []string{"base.env", filepath.Join(dir, "local.env")},
+		Vars:    map[string]string{"A": "config"},
+	}
+	environ, overrides, err := env.Resolve(dir)
+	require.NoError(t, err)
+	require.Contains(t, environ, "WINSVC_INHERITED=yes")
+	require.Contains(t, environ, "A=config")
+	require.Contains(t, environ, "B=local")
+	require.Equal(t, []string{"A=config", "B=local"}, overrides)
+
+	env.Inherit = false
+	environ, _, err = env.Resolve(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"A=config", "B=local"}, environ)
+
+	env.Files = []string{"missing.env"}
+	_, _, err = env.Resolve(dir)
+	require.Error(t, err)
+}
+
+func TestMaskEnv(t *testing.T) {
+	require.Equal(t,
+		[]string{"DB_HOST=localhost", "DB_PASSWORD=****", "api_token=****", "SECRET_KEY=****"},
+		MaskEnv([]string{"DB_HOST=localhost", "DB_PASSWORD=hunter2", "api_token=abc", "SECRET_KEY=xyz"}),
+	)
+}
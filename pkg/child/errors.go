@@ -0,0 +1,12 @@
+package child
+
+import "github.com/pkg/errors"
+
+var (
+	ErrFailedToStart  = errors.New("failed to start process")
+	ErrInvalidSignal  = errors.New("invalid stop signal")
+	ErrInvalidTimeout = errors.New("stop timeouts must not be negative")
+	ErrFailedToKill   = errors.New("failed to kill process")
+	ErrStillRunning   = errors.New("process is still running after kill")
+	ErrInvalidEnvLine = errors.New("invalid env file line")
+)
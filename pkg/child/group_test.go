@@ -0,0 +1,96 @@
+//go:build !windows
+
+package child
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+)
+
+// sleepers returns the PIDs the shell wrote to pidFile
+func sleepers(t *testing.T, pidFile string) []int {
+	var pids []int
+	require.Eventually(t, func() bool {
+		content, err := os.ReadFile(pidFile)
+		if err != nil {
+			return false
+		}
+		pids = pids[:0]
+		for _, field := range strings.Fields(string(content)) {
+			pid, err := strconv.Atoi(field)
+			require.NoError(t, err)
+			pids = append(pids, pid)
+		}
+		return len(pids) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	return pids
+}
+
+// alive reports whether pid is running, zombies are not counted as they may never be reaped in containers
+func alive(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return syscall.Kill(pid, 0) == nil
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func forkSleepers(pidFile string) string {
+	return "sleep 60 & echo $! >> " + pidFile + "; sleep 60 & echo $! >> " + pidFile + "; "
+}
+
+func TestStopKillsProcessTree(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pids")
+	p, exited := startShell(t, forkSleepers(pidFile)+"wait", true)
+	pids := sleepers(t, pidFile)
+
+	seq := StopSequence{Signal: SignalTerminate, Timeout: 5 * time.Second}
+	require.NoError(t, Stop(p, exited, seq, clock.System{}, &strings.Builder{}))
+	for _, pid := range pids {
+		require.Eventually(t, func() bool { return !alive(pid) }, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestCleanupKillsOrphans(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pids")
+	p, exited := startShell(t, forkSleepers(pidFile)+"exit 1", true)
+	pids := sleepers(t, pidFile)
+	<-exited
+
+	for _, pid := range pids {
+		require.True(t, alive(pid))
+	}
+	require.NoError(t, p.Cleanup())
+	for _, pid := range pids {
+		require.Eventually(t, func() bool { return !alive(pid) }, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestStopChildOnly(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pids")
+	p, exited := startShell(t, forkSleepers(pidFile)+"wait", false)
+	pids := sleepers(t, pidFile)
+	defer func() {
+		for _, pid := range pids {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}()
+
+	seq := StopSequence{Signal: SignalTerminate, Timeout: 5 * time.Second}
+	require.NoError(t, Stop(p, exited, seq, clock.System{}, &strings.Builder{}))
+	for _, pid := range pids {
+		require.True(t, alive(pid))
+	}
+}
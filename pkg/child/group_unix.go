@@ -0,0 +1,50 @@
+//go:build !windows
+
+package child
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// group is the process group led by the child process
+type group struct{}
+
+func prepareGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func attachGroup(cmd *exec.Cmd) (group, error) {
+	return group{}, nil
+}
+
+func (group) signal(p *os.Process, s Signal) error {
+	sig := syscall.SIGTERM
+	if s == SignalInterrupt {
+		sig = syscall.SIGINT
+	}
+
+	return killGroup(p.Pid, sig)
+}
+
+func (group) kill(p *os.Process) error {
+	return killGroup(p.Pid, syscall.SIGKILL)
+}
+
+func (group) release(p *os.Process) error {
+	return killGroup(p.Pid, syscall.SIGKILL)
+}
+
+func killGroup(pgid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pgid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package child
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// group is the job object holding the child process and every process it spawns
+type group struct {
+	job windows.Handle
+}
+
+// prepareGroup creates the child process suspended, so it can not spawn processes before it is assigned to the job
+func prepareGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+}
+
+// attachGroup assigns the suspended child process to a new job object and resumes it
+func attachGroup(cmd *exec.Cmd) (group, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return group{}, err
+	}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+	if _, err := windows.SetInformationJobObject(
+		job,
+		windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+	); err != nil {
+		windows.CloseHandle(job)
+		return group{}, err
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return group{}, err
+	}
+	defer windows.CloseHandle(process)
+
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		windows.CloseHandle(job)
+		return group{}, err
+	}
+	if err := resumeProcess(uint32(cmd.Process.Pid)); err != nil {
+		windows.CloseHandle(job)
+		return group{}, err
+	}
+
+	return group{job: job}, nil
+}
+
+// resumeProcess resumes the main thread of the process created suspended, the only thread it has
+func resumeProcess(pid uint32) error {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(snapshot)
+
+	entry := windows.ThreadEntry32{Size: uint32(unsafe.Sizeof(windows.ThreadEntry32{}))}
+	for err = windows.Thread32First(snapshot, &entry); err == nil; err = windows.Thread32Next(snapshot, &entry) {
+		if entry.OwnerProcessID != pid {
+			continue
+		}
+		thread, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, entry.ThreadID)
+		if err != nil {
+			return err
+		}
+		_, err = windows.ResumeThread(thread)
+		windows.CloseHandle(thread)
+		return err
+	}
+	if !errors.Is(err, windows.ERROR_NO_MORE_FILES) {
+		return err
+	}
+
+	return errors.Errorf("no thread of process %d to resume", pid)
+}
+
+// signal relies on the Ctrl+C event reaching every process attached to the child's console
+func (g group) signal(p *os.Process, s Signal) error {
+	return sendSignal(p, s)
+}
+
+func (g group) kill(p *os.Process) error {
+	return windows.TerminateJobObject(g.job, 1)
+}
+
+// release closes the job object, which kills the remaining processes in it
+func (g group) release(p *os.Process) error {
+	return windows.CloseHandle(g.job)
+}
@@ -0,0 +1,77 @@
+package child
+
+import (
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// Process is a started child process. Unless it was started with tree set to false,
+// its descendants are tracked as well (a process group on Unix, a job object on Windows)
+// and are signalled and killed together with it.
+type Process struct {
+	cmd      *exec.Cmd
+	tree     bool
+	group    group
+	released bool
+}
+
+// Start starts cmd, tracking its descendants if tree is set
+func Start(cmd *exec.Cmd, tree bool) (*Process, error) {
+	p := &Process{cmd: cmd, tree: tree}
+	if tree {
+		prepareGroup(cmd)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(ErrFailedToStart, err.Error())
+	}
+	if tree {
+		g, err := attachGroup(cmd)
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, errors.Wrap(ErrFailedToStart, err.Error())
+		}
+		p.group = g
+	}
+
+	return p, nil
+}
+
+func (p *Process) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+// Wait waits for the child process to exit
+func (p *Process) Wait() error {
+	return p.cmd.Wait()
+}
+
+// Signal sends s to the child process, or to its whole tree
+func (p *Process) Signal(s Signal) error {
+	if p.tree {
+		return p.group.signal(p.cmd.Process, s)
+	}
+
+	return sendSignal(p.cmd.Process, s)
+}
+
+// Kill kills the child process, or its whole tree
+func (p *Process) Kill() error {
+	if p.tree {
+		return p.group.kill(p.cmd.Process)
+	}
+
+	return p.cmd.Process.Kill()
+}
+
+// Cleanup kills descendants left running after the child process exited
+// and releases the resources used to track them, it is safe to call more than once
+func (p *Process) Cleanup() error {
+	if !p.tree || p.released {
+		return nil
+	}
+	p.released = true
+
+	return p.group.release(p.cmd.Process)
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package child
+
+import (
+	"os"
+	"syscall"
+)
+
+func sendSignal(p *os.Process, s Signal) error {
+	if s == SignalInterrupt {
+		return p.Signal(syscall.SIGINT)
+	}
+
+	return p.Signal(syscall.SIGTERM)
+}
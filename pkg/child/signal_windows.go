@@ -0,0 +1,12 @@
+package child
+
+import (
+	"os"
+
+	"github.com/nixpare/process"
+)
+
+// sendSignal simulates Ctrl+C for both signals, Windows has no SIGTERM equivalent for console processes
+func sendSignal(p *os.Process, s Signal) error {
+	return process.StopProcess(p.Pid)
+}
@@ -0,0 +1,127 @@
+package child
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+type Signal string
+
+const (
+	SignalInterrupt Signal = "interrupt"
+	SignalTerminate Signal = "terminate"
+)
+
+const defaultStopTimeout = 10 * time.Second
+
+// StopSequence is the parsed form of config.Stop with defaults applied
+type StopSequence struct {
+	Signal        Signal
+	Timeout       time.Duration
+	SecondSignal  Signal
+	SecondTimeout time.Duration
+	ChildOnly     bool
+}
+
+// NewStopSequence parses the stop section of the service config
+func NewStopSequence(cfg config.Stop) (StopSequence, error) {
+	seq := StopSequence{
+		Signal:        Signal(cfg.Signal),
+		Timeout:       time.Duration(cfg.TimeoutMs) * time.Millisecond,
+		SecondSignal:  Signal(cfg.SecondSignal),
+		SecondTimeout: time.Duration(cfg.SecondTimeoutMs) * time.Millisecond,
+		ChildOnly:     cfg.ChildOnly,
+	}
+
+	if seq.Signal == "" {
+		seq.Signal = SignalTerminate
+	}
+	for _, s := range []Signal{seq.Signal, seq.SecondSignal} {
+		switch s {
+		case "", SignalInterrupt, SignalTerminate:
+		default:
+			return seq, errors.Wrapf(ErrInvalidSignal, "%q", s)
+		}
+	}
+	if seq.Timeout < 0 || seq.SecondTimeout < 0 {
+		return seq, ErrInvalidTimeout
+	}
+	if seq.Timeout == 0 {
+		seq.Timeout = defaultStopTimeout
+	}
+	if seq.SecondSignal != "" && seq.SecondTimeout == 0 {
+		seq.SecondTimeout = seq.Timeout
+	}
+
+	return seq, nil
+}
+
+// Stop runs the stop sequence against p, whose Wait result is delivered on exited:
+// the graceful signal, the optional second signal and finally a hard kill,
+// each step waiting for the process to exit up to its timeout.
+// Every step is logged to log together with the time it took.
+// Descendants still running once the child process exited are killed.
+// exited must belong to p alone and be buffered, the result of a process Stop gave up on is then never taken for another.
+func Stop(p *Process, exited <-chan error, seq StopSequence, clk clock.Clock, log io.Writer) error {
+	start := clk.Now()
+	defer func() {
+		if err := p.Cleanup(); err != nil {
+			log.Write([]byte(fmt.Sprintf("Failed to kill descendants of process %d: %s\n", p.Pid(), err.Error())))
+		}
+	}()
+
+	if seq.Signal != "" {
+		if stopped, err := signalAndWait(p, exited, seq.Signal, seq.Timeout, clk, log); stopped || err != nil {
+			return err
+		}
+	}
+	if seq.SecondSignal != "" {
+		if stopped, err := signalAndWait(p, exited, seq.SecondSignal, seq.SecondTimeout, clk, log); stopped || err != nil {
+			return err
+		}
+	}
+
+	log.Write([]byte(fmt.Sprintf("Killing process %d\n", p.Pid())))
+	stepStart := clk.Now()
+	if err := p.Kill(); err != nil {
+		return errors.Wrap(ErrFailedToKill, err.Error())
+	}
+	select {
+	case <-exited:
+		log.Write([]byte(fmt.Sprintf("Process %d killed after %s, stop took %s\n", p.Pid(), clk.Now().Sub(stepStart), clk.Now().Sub(start))))
+		return nil
+	case <-clk.After(seq.Timeout):
+		return ErrStillRunning
+	}
+}
+
+func signalAndWait(p *Process, exited <-chan error, s Signal, timeout time.Duration, clk clock.Clock, log io.Writer) (stopped bool, err error) {
+	log.Write([]byte(fmt.Sprintf("Sending %s signal to process %d\n", s, p.Pid())))
+	start := clk.Now()
+	if err := p.Signal(s); err != nil {
+		// the process may have exited on its own in the meantime
+		select {
+		case <-exited:
+			log.Write([]byte(fmt.Sprintf("Process %d exited after %s\n", p.Pid(), clk.Now().Sub(start))))
+			return true, nil
+		default:
+		}
+		log.Write([]byte(fmt.Sprintf("Failed to send %s signal to process %d: %s\n", s, p.Pid(), err.Error())))
+		return false, nil
+	}
+
+	select {
+	case <-exited:
+		log.Write([]byte(fmt.Sprintf("Process %d exited after %s\n", p.Pid(), clk.Now().Sub(start))))
+		return true, nil
+	case <-clk.After(timeout):
+		log.Write([]byte(fmt.Sprintf("Process %d did not exit within %s after %s signal\n", p.Pid(), timeout, s)))
+		return false, nil
+	}
+}
@@ -0,0 +1,84 @@
+//go:build !windows
+
+package child
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func startShell(t *testing.T, script string, tree bool) (*Process, chan error) {
+	p, err := Start(exec.Command("sh", "-c", script), tree)
+	require.NoError(t, err)
+	exited := make(chan error, 1)
+	go func() {
+		exited <- p.Wait()
+	}()
+	// give the shell time to install its traps
+	time.Sleep(100 * time.Millisecond)
+
+	return p, exited
+}
+
+func TestNewStopSequenceDefaults(t *testing.T) {
+	seq, err := NewStopSequence(config.Stop{SecondSignal: "interrupt"})
+	require.NoError(t, err)
+	require.Equal(t, SignalTerminate, seq.Signal)
+	require.Equal(t, defaultStopTimeout, seq.Timeout)
+	require.Equal(t, defaultStopTimeout, seq.SecondTimeout)
+
+	_, err = NewStopSequence(config.Stop{Signal: "hangup"})
+	require.ErrorIs(t, err, ErrInvalidSignal)
+	_, err = NewStopSequence(config.Stop{TimeoutMs: -1})
+	require.ErrorIs(t, err, ErrInvalidTimeout)
+}
+
+func TestStopGraceful(t *testing.T) {
+	p, exited := startShell(t, "sleep 10", false)
+	var log bytes.Buffer
+
+	seq := StopSequence{Signal: SignalTerminate, Timeout: 5 * time.Second}
+	require.NoError(t, Stop(p, exited, seq, clock.System{}, &log))
+	require.Contains(t, log.String(), "Sending terminate signal")
+	require.Contains(t, log.String(), "exited after")
+	require.NotContains(t, log.String(), "Killing")
+}
+
+func TestStopSecondSignal(t *testing.T) {
+	p, exited := startShell(t, `trap "" TERM; trap "exit 0" INT; while true; do sleep 0.05; done`, false)
+	var log bytes.Buffer
+
+	seq := StopSequence{Signal: SignalTerminate, Timeout: 200 * time.Millisecond, SecondSignal: SignalInterrupt, SecondTimeout: 5 * time.Second}
+	require.NoError(t, Stop(p, exited, seq, clock.System{}, &log))
+	require.Contains(t, log.String(), "did not exit within 200ms after terminate signal")
+	require.Contains(t, log.String(), "Sending interrupt signal")
+	require.NotContains(t, log.String(), "Killing")
+}
+
+func TestStopEscalatesToKill(t *testing.T) {
+	p, exited := startShell(t, `trap "" TERM INT; while true; do sleep 0.05; done`, false)
+	var log bytes.Buffer
+
+	seq := StopSequence{Signal: SignalTerminate, Timeout: 200 * time.Millisecond, SecondSignal: SignalInterrupt, SecondTimeout: 200 * time.Millisecond}
+	start := time.Now()
+	require.NoError(t, Stop(p, exited, seq, clock.System{}, &log))
+	require.Less(t, time.Since(start), 2*time.Second)
+	require.Contains(t, log.String(), "after interrupt signal")
+	require.Contains(t, log.String(), "killed after")
+}
+
+func TestStopExitedProcess(t *testing.T) {
+	p, exited := startShell(t, "exit 0", false)
+	var log bytes.Buffer
+
+	seq := StopSequence{Signal: SignalTerminate, Timeout: time.Second}
+	require.NoError(t, Stop(p, exited, seq, clock.System{}, &log))
+	require.NotContains(t, log.String(), "Killing")
+}
@@ -5,7 +5,7 @@ import "github.com/urfave/cli"
 func New(svcName string) *cli.App {
 	return &cli.App{
 		Name:     svcName,
-		Flags:    []cli.Flag{CfgFlag},
+		Flags:    []cli.Flag{CfgFlag, ProfileFlag, OverlayFlag},
 		Before:   SetupService,
 		Commands: ServiceCmd,
 	}
@@ -1,44 +1,202 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
 	"github.com/pkg/errors"
 	"github.com/urfave/cli"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/install"
+	"github.com/edwardezs/win-svc/pkg/service"
 )
 
 // ServiceCmd - cli-commands for running app as Windows service in background
 // Usage:
 //
 //	./service.exe install
-//	./service.exe start
-//	./service.exe stop
-//	./service.exe delete
+//	./service.exe reconfigure [--dry-run]
+//	./service.exe ensure [--dry-run]
+//	./service.exe start [--wait=false] [--timeout 30s]
+//	./service.exe stop [--wait=false] [--timeout 30s]
+//	./service.exe restart [--wait=false] [--timeout 30s]
+//	./service.exe delete [--wait=false] [--timeout 30s]
+//	./service.exe reload
+//	./service.exe status [--output text|json]
+//	./service.exe run
+//	./service.exe validate
+//	./service.exe config show [--resolved]
+//	./service.exe secret set <name> [value]
+//	./service.exe secret get <name>
+//	./service.exe secret list
+//	./service.exe secret rm <name>
 //
-// Note:  	admin rights are required to install/start/stop/delete app as Windows service
+// Note:  	admin rights are required to install/start/stop/delete/reload app as Windows service or systemd unit
 var ServiceCmd = []cli.Command{
 	{
 		Name:   "install",
 		Usage:  "Install the service",
 		Action: serviceInstallCmd,
 	},
+	{
+		Name:   "reconfigure",
+		Usage:  "Apply the changed install settings of the configuration file to the installed service",
+		Flags:  dryRunFlags,
+		Action: serviceReconfigureCmd,
+	},
+	{
+		Name:   "ensure",
+		Usage:  "Install the service if it is not installed, reconfigure it otherwise",
+		Flags:  dryRunFlags,
+		Action: serviceEnsureCmd,
+	},
 	{
 		Name:   "start",
 		Usage:  "Start the service",
+		Flags:  waitFlags,
 		Action: serviceStartCmd,
 	},
 	{
 		Name:   "stop",
 		Usage:  "Stop the service",
+		Flags:  waitFlags,
 		Action: serviceStopCmd,
 	},
+	{
+		Name:   "restart",
+		Usage:  "Stop the service if it is running and start it again",
+		Flags:  waitFlags,
+		Action: serviceRestartCmd,
+	},
 	{
 		Name:   "delete",
 		Usage:  "Delete the service",
+		Flags:  waitFlags,
 		Action: serviceDeleteCmd,
 	},
+	{
+		Name:   "reload",
+		Usage:  "Reload the configuration file of the running service",
+		Action: serviceReloadCmd,
+	},
+	{
+		Name:  "status",
+		Usage: "Print the state of the service and the runtime status of its children",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "output",
+				Value: "text",
+				Usage: "Output format, text or json",
+			},
+		},
+		Action: serviceStatusCmd,
+	},
+	{
+		Name:   "run",
+		Usage:  "Run the service in the foreground until interrupted, this is how systemd runs the service",
+		Action: serviceRunCmd,
+	},
+	{
+		Name:   "validate",
+		Usage:  "Validate the configuration file",
+		Action: serviceValidateCmd,
+	},
+	{
+		Name:  "config",
+		Usage: "Inspect the configuration file",
+		Subcommands: []cli.Command{
+			{
+				Name:  "show",
+				Usage: "Print the configuration file with the profile and overlays applied",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "resolved",
+						Usage: "Resolve the variables of the configuration file",
+					},
+				},
+				Action: configShowCmd,
+			},
+		},
+	},
+	{
+		Name:  secretCmdName,
+		Usage: "Manage the encrypted secrets referenced by the configuration file as ${secret:name}",
+		Subcommands: []cli.Command{
+			{
+				Name:      "set",
+				Usage:     "Set a secret, the value is read from the standard input if it is not given",
+				ArgsUsage: "<name> [value]",
+				Action:    secretSetCmd,
+			},
+			{
+				Name:      "get",
+				Usage:     "Print the value of a secret",
+				ArgsUsage: "<name>",
+				Action:    secretGetCmd,
+			},
+			{
+				Name:   "list",
+				Usage:  "List the names of the secrets",
+				Action: secretListCmd,
+			},
+			{
+				Name:      "rm",
+				Usage:     "Remove a secret",
+				ArgsUsage: "<name>",
+				Action:    secretRemoveCmd,
+			},
+		},
+	},
+}
+
+// waitFlags control how long the commands wait for the service to reach the requested state
+var waitFlags = []cli.Flag{
+	cli.BoolTFlag{
+		Name:  "wait",
+		Usage: "Wait until the service reaches the requested state, --wait=false returns once the request is sent",
+	},
+	cli.DurationFlag{
+		Name:  "timeout",
+		Value: service.DefaultTimeout,
+		Usage: "How long to wait for the service to reach the requested state",
+	},
+}
+
+// dryRunFlags make the commands changing the installed service only print their plan
+var dryRunFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Print the changes without applying them",
+	},
+}
+
+// setupWait applies waitFlags to the service
+func setupWait(ctx *cli.Context) {
+	appCtx.svc.NoWait = !ctx.BoolT("wait")
+	appCtx.svc.Timeout = ctx.Duration("timeout")
 }
 
+// commandContext returns the context of a management command, canceled once the command is interrupted
+func commandContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// secretCmdName is the command managing the secrets store, it runs without resolving the secrets of the configuration
+const secretCmdName = "secret"
+
 func serviceStartCmd(ctx *cli.Context) error {
-	if err := appCtx.svc.Start(); err != nil {
+	cmdCtx, stop := commandContext()
+	defer stop()
+
+	setupWait(ctx)
+	if err := appCtx.svc.Start(cmdCtx); err != nil {
 		return errors.Wrap(err, "failed to start service")
 	}
 
@@ -46,7 +204,11 @@ func serviceStartCmd(ctx *cli.Context) error {
 }
 
 func serviceStopCmd(ctx *cli.Context) error {
-	if err := appCtx.svc.Stop(); err != nil {
+	cmdCtx, stop := commandContext()
+	defer stop()
+
+	setupWait(ctx)
+	if err := appCtx.svc.Stop(cmdCtx); err != nil {
 		return errors.Wrap(err, "failed to stop service")
 	}
 
@@ -54,17 +216,276 @@ func serviceStopCmd(ctx *cli.Context) error {
 }
 
 func serviceInstallCmd(ctx *cli.Context) error {
-	if err := appCtx.svc.Install(); err != nil {
+	cmdCtx, stop := commandContext()
+	defer stop()
+
+	if err := appCtx.svc.Install(cmdCtx); err != nil {
 		return errors.Wrap(err, "failed to install service")
 	}
 
 	return nil
 }
 
+func serviceReconfigureCmd(ctx *cli.Context) error {
+	cmdCtx, stop := commandContext()
+	defer stop()
+
+	dryRun := ctx.Bool("dry-run")
+	changes, err := appCtx.svc.Reconfigure(cmdCtx, dryRun)
+	if err != nil {
+		return errors.Wrap(err, "failed to reconfigure service")
+	}
+	printChanges(ctx.App.Writer, changes, dryRun)
+
+	return nil
+}
+
+func serviceEnsureCmd(ctx *cli.Context) error {
+	cmdCtx, stop := commandContext()
+	defer stop()
+
+	dryRun := ctx.Bool("dry-run")
+	installed, changes, err := appCtx.svc.Ensure(cmdCtx, dryRun)
+	if err != nil {
+		return errors.Wrap(err, "failed to ensure service")
+	}
+	if installed {
+		if dryRun {
+			fmt.Fprintf(ctx.App.Writer, "Service %s would be installed (dry run)\n", appCtx.cfg.Name)
+		} else {
+			fmt.Fprintf(ctx.App.Writer, "Service %s installed\n", appCtx.cfg.Name)
+		}
+		return nil
+	}
+	printChanges(ctx.App.Writer, changes, dryRun)
+
+	return nil
+}
+
+// printChanges prints the changes of the install settings of the service, one per line
+func printChanges(w io.Writer, changes []install.Change, dryRun bool) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "Service is up to date")
+		return
+	}
+	for _, change := range changes {
+		fmt.Fprintln(w, change)
+	}
+	if dryRun {
+		fmt.Fprintf(w, "%d changes not applied (dry run)\n", len(changes))
+		return
+	}
+	fmt.Fprintf(w, "%d changes applied, they take effect on the next start of the service\n", len(changes))
+}
+
+func serviceRestartCmd(ctx *cli.Context) error {
+	cmdCtx, stop := commandContext()
+	defer stop()
+
+	setupWait(ctx)
+	if err := appCtx.svc.Restart(cmdCtx); err != nil {
+		return errors.Wrap(err, "failed to restart service")
+	}
+
+	return nil
+}
+
 func serviceDeleteCmd(ctx *cli.Context) error {
-	if err := appCtx.svc.Delete(); err != nil {
+	cmdCtx, stop := commandContext()
+	defer stop()
+
+	setupWait(ctx)
+	if err := appCtx.svc.Delete(cmdCtx); err != nil {
 		return errors.Wrap(err, "failed to uninstall service")
 	}
 
 	return nil
 }
+
+func serviceReloadCmd(ctx *cli.Context) error {
+	cmdCtx, stop := commandContext()
+	defer stop()
+
+	if err := appCtx.svc.Reload(cmdCtx); err != nil {
+		return errors.Wrap(err, "failed to reload service")
+	}
+
+	return nil
+}
+
+func serviceStatusCmd(ctx *cli.Context) error {
+	cmdCtx, stop := commandContext()
+	defer stop()
+
+	output := ctx.String("output")
+	if output != "text" && output != "json" {
+		return cli.NewExitError(fmt.Sprintf("unknown output format %q, expected text or json", output), 1)
+	}
+
+	report, err := appCtx.svc.Status(cmdCtx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get service status")
+	}
+	if output == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to encode status")
+		}
+		fmt.Fprintln(ctx.App.Writer, string(data))
+		return nil
+	}
+	printReport(ctx.App.Writer, appCtx.cfg.Name, report)
+
+	return nil
+}
+
+// printReport prints the status of the service as text
+func printReport(w io.Writer, name string, report service.Report) {
+	fmt.Fprintf(w, "Service:     %s\n", name)
+	fmt.Fprintf(w, "State:       %s\n", report.State)
+	if report.PID != 0 {
+		fmt.Fprintf(w, "PID:         %d\n", report.PID)
+	}
+	if report.StartedAt != nil {
+		fmt.Fprintf(w, "Started:     %s\n", report.StartedAt.Format(time.RFC3339))
+	}
+
+	for _, c := range report.Children {
+		fmt.Fprintln(w)
+		if c.Name != "" {
+			fmt.Fprintf(w, "Child:       %s\n", c.Name)
+		}
+		fmt.Fprintf(w, "Child state: %s\n", c.State)
+		if c.PID != 0 {
+			fmt.Fprintf(w, "Child PID:   %d\n", c.PID)
+			fmt.Fprintf(w, "Uptime:      %s\n", (time.Duration(c.UptimeMs) * time.Millisecond).Round(time.Second))
+		}
+		fmt.Fprintf(w, "Restarts:    %d\n", c.Restarts)
+		if c.LastExit != nil {
+			reason := fmt.Sprintf("code %d", c.LastExit.Code)
+			if c.LastExit.Error != "" {
+				reason = c.LastExit.Error
+			}
+			fmt.Fprintf(w, "Last exit:   %s at %s\n", reason, c.LastExit.Time.Format(time.RFC3339))
+		}
+		if c.Backoff != nil {
+			fmt.Fprintf(w, "Backoff:     attempt %d, restart in %s at %s\n",
+				c.Backoff.Attempt, time.Duration(c.Backoff.DelayMs)*time.Millisecond, c.Backoff.RestartAt.Format(time.RFC3339))
+		}
+	}
+}
+
+func serviceRunCmd(ctx *cli.Context) error {
+	if exitCode := appCtx.svc.RunForeground(ctx.App.Writer); exitCode != 0 {
+		return cli.NewExitError(fmt.Sprintf("service stopped with exit code %d", exitCode), int(exitCode))
+	}
+
+	return nil
+}
+
+func serviceValidateCmd(ctx *cli.Context) error {
+	err := service.Validate(appCtx.cfg)
+	if err == nil {
+		fmt.Fprintln(ctx.App.Writer, "Configuration is valid")
+		return nil
+	}
+
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	for _, fieldErr := range validationErr.Errors {
+		fmt.Fprintln(ctx.App.Writer, appCtx.cfg.Redact(fieldErr.Error()))
+	}
+
+	return cli.NewExitError(fmt.Sprintf("configuration is invalid: %d problems found", len(validationErr.Errors)), 1)
+}
+
+func configShowCmd(ctx *cli.Context) error {
+	cfg := appCtx.cfg
+	if !ctx.Bool("resolved") {
+		var err error
+		if cfg, err = config.Load(appCtx.cfgPath, appCtx.overlays...); err != nil {
+			return errors.Wrap(err, "failed to load config")
+		}
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode config")
+	}
+	fmt.Fprintln(ctx.App.Writer, appCtx.cfg.Redact(string(data)))
+
+	return nil
+}
+
+func secretSetCmd(ctx *cli.Context) error {
+	name := ctx.Args().First()
+	if name == "" {
+		return cli.NewExitError("secret name is required", 1)
+	}
+	value := ctx.Args().Get(1)
+	if len(ctx.Args()) < 2 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return errors.Wrap(err, "failed to read secret value")
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+
+	store, err := config.OpenSecrets(appCtx.cfgPath, appCtx.overlays...)
+	if err != nil {
+		return errors.Wrap(err, "failed to open secrets")
+	}
+	if err := store.Set(name, value); err != nil {
+		return errors.Wrap(err, "failed to set secret")
+	}
+	if err := store.Save(); err != nil {
+		return errors.Wrap(err, "failed to save secrets")
+	}
+	fmt.Fprintf(ctx.App.Writer, "Secret %s set\n", name)
+
+	return nil
+}
+
+func secretGetCmd(ctx *cli.Context) error {
+	store, err := config.OpenSecrets(appCtx.cfgPath, appCtx.overlays...)
+	if err != nil {
+		return errors.Wrap(err, "failed to open secrets")
+	}
+	value, err := store.Get(ctx.Args().First())
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Fprintln(ctx.App.Writer, value)
+
+	return nil
+}
+
+func secretListCmd(ctx *cli.Context) error {
+	store, err := config.OpenSecrets(appCtx.cfgPath, appCtx.overlays...)
+	if err != nil {
+		return errors.Wrap(err, "failed to open secrets")
+	}
+	for _, name := range store.Names() {
+		fmt.Fprintln(ctx.App.Writer, name)
+	}
+
+	return nil
+}
+
+func secretRemoveCmd(ctx *cli.Context) error {
+	store, err := config.OpenSecrets(appCtx.cfgPath, appCtx.overlays...)
+	if err != nil {
+		return errors.Wrap(err, "failed to open secrets")
+	}
+	if err := store.Remove(ctx.Args().First()); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	if err := store.Save(); err != nil {
+		return errors.Wrap(err, "failed to save secrets")
+	}
+	fmt.Fprintf(ctx.App.Writer, "Secret %s removed\n", ctx.Args().First())
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/service"
+)
+
+// Exit codes of the commands failed because of the service manager, other failures exit with ExitCodeFailure
+const (
+	ExitCodeFailure          = 1
+	ExitCodeNotInstalled     = 3
+	ExitCodeAlreadyInstalled = 4
+	ExitCodeAccessDenied     = 5
+	ExitCodeTimeout          = 6
+	ExitCodeUnsupported      = 7
+)
+
+// ExitCode returns the process exit code of the error returned by a command
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, service.ErrAccessDenied):
+		return ExitCodeAccessDenied
+	case errors.Is(err, service.ErrServiceNotExist):
+		return ExitCodeNotInstalled
+	case errors.Is(err, service.ErrServiceAlreadyExist):
+		return ExitCodeAlreadyInstalled
+	case errors.Is(err, service.ErrStartTimeoutExceeded), errors.Is(err, service.ErrStopTimeoutExceeded):
+		return ExitCodeTimeout
+	case errors.Is(err, service.ErrUnsupportedPlatform):
+		return ExitCodeUnsupported
+	default:
+		return ExitCodeFailure
+	}
+}
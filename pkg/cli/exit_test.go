@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/service"
+)
+
+func TestExitCode(t *testing.T) {
+	for err, want := range map[error]int{
+		nil:                            0,
+		errors.New("invalid config"):   ExitCodeFailure,
+		service.ErrUnsupportedPlatform: ExitCodeUnsupported,
+		errors.Wrap(&service.OpError{Op: service.OpStart, Name: "svc", Kind: service.ErrServiceNotExist}, "failed to start service"): ExitCodeNotInstalled,
+		&service.OpError{Op: service.OpInstall, Name: "svc", Kind: service.ErrServiceAlreadyExist}:                                   ExitCodeAlreadyInstalled,
+		&service.OpError{Op: service.OpStop, Name: "svc", Kind: service.ErrStopTimeoutExceeded}:                                      ExitCodeTimeout,
+		&service.OpError{Op: service.OpStart, Name: "svc", Kind: service.ErrFailedToConnectToServiceManager, Err: os.ErrPermission}:  ExitCodeAccessDenied,
+	} {
+		require.Equal(t, want, ExitCode(err), "%v", err)
+	}
+}
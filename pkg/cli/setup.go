@@ -1,6 +1,11 @@
 package cli
 
 import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+
 	"github.com/pkg/errors"
 	"github.com/urfave/cli"
 
@@ -19,20 +24,88 @@ var CfgFlag = &cli.StringFlag{
 	Usage:    "Configuration file",
 }
 
+// ProfileFlag is the cli-flag selecting the profile overlay applied to the configuration file
+// Usage: 		./service.exe <-profile> <FLAG_VALUE> ...
+// Required:	false
+// Env:			SERVICE_PROFILE
+var ProfileFlag = &cli.StringFlag{
+	Name:   "profile",
+	EnvVar: config.ProfileEnvVar,
+	Usage:  "Profile of the configuration file, applies the overlay <config name>.<profile>.json",
+}
+
+// OverlayFlag is the cli-flag adding overlay files applied to the configuration file in order, after the profile overlay
+// Usage: 		./service.exe <-overlay> <FLAG_VALUE> [<-overlay> <FLAG_VALUE>] ...
+// Required:	false
+var OverlayFlag = &cli.StringSliceFlag{
+	Name:  "overlay",
+	Usage: "Overlay file applied to the configuration file, can be repeated",
+}
+
 var appCtx AppContext
 
 type AppContext struct {
-	svc *service.WindowsService
-	cfg config.WindowsServiceConfig
+	svc      *service.WindowsService
+	cfg      config.WindowsServiceConfig
+	cfgPath  string
+	overlays []string
 }
 
 // SetupService sets up the AppContext for the Windows service
 func SetupService(ctx *cli.Context) (err error) {
-	appCtx.cfg, err = config.New(ctx.String(CfgFlag.Name))
+	appCtx.cfgPath = ctx.String(CfgFlag.Name)
+	appCtx.overlays = nil
+	if profile := ctx.String(ProfileFlag.Name); profile != "" {
+		appCtx.overlays = append(appCtx.overlays, config.ProfilePath(appCtx.cfgPath, profile))
+	}
+	appCtx.overlays = append(appCtx.overlays, ctx.StringSlice(OverlayFlag.Name)...)
+	// the secrets the configuration refers to may not be set yet
+	if ctx.Args().First() == secretCmdName {
+		return nil
+	}
+
+	appCtx.cfg, err = config.New(appCtx.cfgPath, appCtx.overlays...)
 	if err != nil {
 		return errors.Wrap(err, "failed to load config for Windows service")
 	}
 	appCtx.svc = service.New(appCtx.cfg)
+	// the configuration is reloaded from and installed with absolute paths, the working directory of the service differs
+	if appCtx.svc.ConfigPath, err = filepath.Abs(appCtx.cfgPath); err != nil {
+		return errors.Wrap(err, "failed to resolve config path")
+	}
+	for _, overlay := range appCtx.overlays {
+		path, err := filepath.Abs(overlay)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve overlay path")
+		}
+		appCtx.svc.ConfigOverlays = append(appCtx.svc.ConfigOverlays, path)
+	}
 
 	return nil
 }
+
+// ServiceConfigPaths returns the configuration file and overlays of the service started by the service manager,
+// args are the arguments the service was installed with. A service installed without arguments uses the configuration
+// file next to exePath with the profile overlay selected by the SERVICE_PROFILE environment variable.
+func ServiceConfigPaths(exePath string, args []string) (cfgPath string, overlays []string, err error) {
+	cfgPath = filepath.Join(filepath.Dir(exePath), CfgFlag.Value)
+	if len(args) == 0 {
+		if profile := os.Getenv(config.ProfileEnvVar); profile != "" {
+			overlays = append(overlays, config.ProfilePath(cfgPath, profile))
+		}
+		return cfgPath, overlays, nil
+	}
+
+	flags := flag.NewFlagSet(filepath.Base(exePath), flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&cfgPath, CfgFlag.Name, cfgPath, CfgFlag.Usage)
+	flags.Func(OverlayFlag.Name, OverlayFlag.Usage, func(overlay string) error {
+		overlays = append(overlays, overlay)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return "", nil, errors.Wrap(err, "failed to parse service arguments")
+	}
+
+	return cfgPath, overlays, nil
+}
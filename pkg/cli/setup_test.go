@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestServiceConfigPaths(t *testing.T) {
+	exePath := filepath.Join("opt", "svc", "service.exe")
+	defaultPath := filepath.Join("opt", "svc", "service.config.json")
+
+	t.Setenv(config.ProfileEnvVar, "")
+	cfgPath, overlays, err := ServiceConfigPaths(exePath, nil)
+	require.NoError(t, err)
+	require.Equal(t, defaultPath, cfgPath)
+	require.Empty(t, overlays)
+
+	t.Setenv(config.ProfileEnvVar, "prod")
+	cfgPath, overlays, err = ServiceConfigPaths(exePath, nil)
+	require.NoError(t, err)
+	require.Equal(t, defaultPath, cfgPath)
+	require.Equal(t, []string{config.ProfilePath(defaultPath, "prod")}, overlays)
+
+	cfgPath, overlays, err = ServiceConfigPaths(exePath, []string{"-config", "/etc/svc.json", "-overlay", "/etc/a.json", "-overlay", "/etc/b.json"})
+	require.NoError(t, err)
+	require.Equal(t, "/etc/svc.json", cfgPath)
+	require.Equal(t, []string{"/etc/a.json", "/etc/b.json"}, overlays)
+
+	_, _, err = ServiceConfigPaths(exePath, []string{"-unknown"})
+	require.Error(t, err)
+}
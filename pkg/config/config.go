@@ -1,26 +1,292 @@
 package config
 
 import (
-	"github.com/jinzhu/configor"
+	"path/filepath"
+	"strings"
+
 	"github.com/pkg/errors"
 )
 
 type WindowsServiceConfig struct {
-	Name              string   `json:"name"`
-	Description       string   `json:"description"`
-	ParentExecPath    string   `json:"parentExecPath"`
-	ChildExecPath     string   `json:"childExecPath"`
-	ChildExecArgs     []string `json:"childExecArgs,omitempty"`
-	LogFilePath       string   `json:"logFilePath,omitempty"`
-	LogFileMaxSizeMB  int      `json:"logFileMaxSizeMB,omitempty"`
-	LogFileMaxBackups int      `json:"logFileMaxBackups,omitempty"`
-	LogFileMaxAgeDays int      `json:"logFileMaxAgeDays,omitempty"`
-	LogFileCompress   bool     `json:"logFileCompress,omitempty"`
-}
-
-func New(filepath string) (cfg WindowsServiceConfig, err error) {
-	if err := configor.Load(&cfg, filepath); err != nil {
-		return cfg, errors.Wrapf(err, "can not parse config file %s", filepath)
+	Name              string            `json:"name"`
+	Description       string            `json:"description"`
+	ParentExecPath    string            `json:"parentExecPath"`
+	ChildExecPath     string            `json:"childExecPath"`
+	ChildExecArgs     []string          `json:"childExecArgs,omitempty"`
+	ChildWorkDir      string            `json:"childWorkDir,omitempty"`
+	ChildEnv          map[string]string `json:"childEnv,omitempty"`
+	ChildEnvFiles     []string          `json:"childEnvFiles,omitempty"`
+	InheritEnv        *bool             `json:"inheritEnv,omitempty"`
+	LogFilePath       string            `json:"logFilePath,omitempty"`
+	LogFileMaxSizeMB  int               `json:"logFileMaxSizeMB,omitempty"`
+	LogFileMaxBackups int               `json:"logFileMaxBackups,omitempty"`
+	LogFileMaxAgeDays int               `json:"logFileMaxAgeDays,omitempty"`
+	LogFileCompress   bool              `json:"logFileCompress,omitempty"`
+	RestartPolicy     RestartPolicy     `json:"restartPolicy,omitempty"`
+	CrashLoop         CrashLoop         `json:"crashLoop,omitempty"`
+	Stop              Stop              `json:"stop,omitempty"`
+	HealthChecks      []HealthCheck     `json:"healthChecks,omitempty"`
+	Readiness         Readiness         `json:"readiness,omitempty"`
+	Hooks             Hooks             `json:"hooks,omitempty"`
+	Children          []Child           `json:"children,omitempty"`
+	// DisplayName is shown by the service manager, defaults to Name
+	DisplayName string `json:"displayName,omitempty"`
+	// StartType is one of "automatic", "delayed-automatic", "manual" or "disabled", defaults to "manual"
+	StartType string `json:"startType,omitempty"`
+	// Account the service runs as: "LocalSystem" (default), "LocalService", "NetworkService"
+	// or a named account as "DOMAIN\\user", ".\\user" or "user@domain"
+	Account string `json:"account,omitempty"`
+	// Password of the named account, best referenced as ${secret:name}
+	Password string `json:"password,omitempty"`
+	// Dependencies are the services, or groups prefixed with "+", started before the service
+	Dependencies []string `json:"dependencies,omitempty"`
+	// Recovery is what the service manager does when the service process itself fails
+	Recovery Recovery `json:"recovery,omitempty"`
+	// WatchConfig reloads the configuration file once it changes while the service is running
+	WatchConfig bool    `json:"watchConfig,omitempty"`
+	Secrets     Secrets `json:"secrets,omitempty"`
+	Systemd     Systemd `json:"systemd,omitempty"`
+	// secretValues are the decrypted values of the ${secret:name} references, kept to redact them from output
+	secretValues []string
+}
+
+// Child is one of several child processes supervised by the service,
+// its fields mirror the child settings of WindowsServiceConfig
+type Child struct {
+	// Name identifies the child in the service log and in DependsOn of other children
+	Name          string            `json:"name"`
+	ExecPath      string            `json:"execPath"`
+	ExecArgs      []string          `json:"execArgs,omitempty"`
+	WorkDir       string            `json:"workDir,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	EnvFiles      []string          `json:"envFiles,omitempty"`
+	InheritEnv    *bool             `json:"inheritEnv,omitempty"`
+	LogFilePath   string            `json:"logFilePath,omitempty"`
+	RestartPolicy RestartPolicy     `json:"restartPolicy,omitempty"`
+	CrashLoop     CrashLoop         `json:"crashLoop,omitempty"`
+	Stop          Stop              `json:"stop,omitempty"`
+	HealthChecks  []HealthCheck     `json:"healthChecks,omitempty"`
+	Readiness     Readiness         `json:"readiness,omitempty"`
+	Hooks         Hooks             `json:"hooks,omitempty"`
+	// DependsOn are names of children started before this one and stopped after it
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Critical children stop the whole service when they fail, others only degrade it
+	Critical bool `json:"critical,omitempty"`
+}
+
+// Secrets locates the encrypted store of the values referenced as ${secret:name}
+type Secrets struct {
+	// Path of the store, defaults to "secrets.json" in the directory of the configuration file
+	Path string `json:"path,omitempty"`
+	// KeyFile holds the key material of the store, without it the passphrase is read from SERVICE_SECRETS_PASSPHRASE
+	KeyFile string `json:"keyFile,omitempty"`
+}
+
+// Systemd configures how the service is installed on Linux hosts managed by systemd
+type Systemd struct {
+	// UnitDir is the directory the unit file is written to, defaults to /etc/systemd/system
+	UnitDir string `json:"unitDir,omitempty"`
+	// SystemctlPath is the systemctl binary, defaults to systemctl found in PATH
+	SystemctlPath string `json:"systemctlPath,omitempty"`
+}
+
+// Recovery describes the failure actions of the Windows service control manager
+type Recovery struct {
+	// Actions are taken on the first, second and following failures in order, the last one is repeated
+	Actions []RecoveryAction `json:"actions,omitempty"`
+	// ResetPeriodMs is how long the service must not fail for the failure count to reset, rounded up to seconds,
+	// defaults to one day when actions are set
+	ResetPeriodMs int `json:"resetPeriodMs,omitempty"`
+	// Command is the command line run by the "run-command" action
+	Command string `json:"command,omitempty"`
+	// RebootMessage is broadcast to the users before the "reboot" action
+	RebootMessage string `json:"rebootMessage,omitempty"`
+	// OnNonCrashFailures also takes the actions when the service stops with a non-zero exit code
+	OnNonCrashFailures bool `json:"onNonCrashFailures,omitempty"`
+}
+
+// RecoveryAction is a failure action of the service
+type RecoveryAction struct {
+	// Type is one of "restart", "reboot", "run-command" or "none"
+	Type    string `json:"type"`
+	DelayMs int    `json:"delayMs,omitempty"`
+}
+
+// RestartPolicy describes when and how fast the child process is restarted after it exits
+type RestartPolicy struct {
+	// Mode is one of "always", "on-failure" or "never", defaults to "on-failure"
+	Mode           string  `json:"mode,omitempty"`
+	InitialDelayMs int     `json:"initialDelayMs,omitempty"`
+	MaxDelayMs     int     `json:"maxDelayMs,omitempty"`
+	Multiplier     float64 `json:"multiplier,omitempty"`
+	// Jitter is a fraction of the delay, in the range [0, 1], used to randomize it
+	Jitter float64 `json:"jitter,omitempty"`
+	// MaxAttempts is the number of consecutive restarts before giving up, 0 means unlimited
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// OnCleanExit is one of "stop", "restart" or "restart-delayed",
+	// defaults to "restart" for the "always" mode and to "stop" otherwise
+	OnCleanExit      string `json:"onCleanExit,omitempty"`
+	CleanExitDelayMs int    `json:"cleanExitDelayMs,omitempty"`
+	// SuccessExitCodes are exit codes treated as a clean exit in addition to 0
+	SuccessExitCodes []int `json:"successExitCodes,omitempty"`
+	// NoRestartExitCodes are exit codes after which the child is never restarted
+	NoRestartExitCodes []int `json:"noRestartExitCodes,omitempty"`
+}
+
+// CrashLoop describes when repeated restarts of the child process are treated as a crash loop
+type CrashLoop struct {
+	// MaxRestarts is the number of restarts allowed within the window, 0 disables detection
+	MaxRestarts int `json:"maxRestarts,omitempty"`
+	WindowMs    int `json:"windowMs,omitempty"`
+	// StableUptimeMs is how long the child must stay up for the restart counter to reset
+	StableUptimeMs int `json:"stableUptimeMs,omitempty"`
+}
+
+// Stop describes how the child process is asked to exit before it is killed
+type Stop struct {
+	// Signal is "terminate" (default) or "interrupt", both are a Ctrl+C event on Windows
+	Signal    string `json:"signal,omitempty"`
+	TimeoutMs int    `json:"timeoutMs,omitempty"`
+	// SecondSignal is sent if the child is still running after TimeoutMs, empty means kill right away
+	SecondSignal    string `json:"secondSignal,omitempty"`
+	SecondTimeoutMs int    `json:"secondTimeoutMs,omitempty"`
+	// ChildOnly stops only the child process instead of its whole process tree
+	ChildOnly bool `json:"childOnly,omitempty"`
+}
+
+// HealthCheck describes a probe run periodically against the child process
+type HealthCheck struct {
+	// Name identifies the check in the service log, defaults to its type and target
+	Name string `json:"name,omitempty"`
+	// Type is one of "http", "tcp" or "exec"
+	Type string `json:"type"`
+	// URL requested by http checks, which pass on a 2xx or 3xx status
+	URL string `json:"url,omitempty"`
+	// Address in the "host:port" form dialed by tcp checks
+	Address string `json:"address,omitempty"`
+	// Command run by exec checks, which pass on exit code 0
+	Command          []string `json:"command,omitempty"`
+	InitialDelayMs   int      `json:"initialDelayMs,omitempty"`
+	IntervalMs       int      `json:"intervalMs,omitempty"`
+	TimeoutMs        int      `json:"timeoutMs,omitempty"`
+	FailureThreshold int      `json:"failureThreshold,omitempty"`
+}
+
+// Readiness describes the check the child process must pass before the service reports Running
+type Readiness struct {
+	// Type is one of "http", "tcp", "exec", "file" or "notify", empty disables readiness gating
+	Type string `json:"type,omitempty"`
+	// URL, Address and Command are checked as in HealthCheck
+	URL     string   `json:"url,omitempty"`
+	Address string   `json:"address,omitempty"`
+	Command []string `json:"command,omitempty"`
+	// Path of the file created by the child once it is ready, for file checks
+	Path string `json:"path,omitempty"`
+	// Message printed by the child to its output once it is ready, for notify checks, defaults to "READY=1"
+	Message        string `json:"message,omitempty"`
+	IntervalMs     int    `json:"intervalMs,omitempty"`
+	TimeoutMs      int    `json:"timeoutMs,omitempty"`
+	StartTimeoutMs int    `json:"startTimeoutMs,omitempty"`
+}
+
+// Hooks are commands run around the child process lifecycle
+type Hooks struct {
+	// PreStart hooks run before every start of the child process
+	PreStart []Hook `json:"preStart,omitempty"`
+	// PostStart hooks run after every start of the child process, once it is ready
+	PostStart []Hook `json:"postStart,omitempty"`
+	// PreStop hooks run before the child process is stopped by the service
+	PreStop []Hook `json:"preStop,omitempty"`
+	// PostStop hooks run after every exit of the child process
+	PostStop []Hook `json:"postStop,omitempty"`
+}
+
+// Hook is a command run at a point of the child process lifecycle
+type Hook struct {
+	// Name identifies the hook in the service log, defaults to the command
+	Name      string            `json:"name,omitempty"`
+	Command   []string          `json:"command"`
+	TimeoutMs int               `json:"timeoutMs,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	// OnFailure is "abort" (default) or "continue"
+	OnFailure string `json:"onFailure,omitempty"`
+}
+
+// ChildConfigs returns the children in start order, dependencies first.
+// Without Children, the single child described by the child settings of the config is returned,
+// it is critical and has the name of neither the service nor any other child.
+func (cfg WindowsServiceConfig) ChildConfigs() ([]Child, error) {
+	if len(cfg.Children) == 0 {
+		return []Child{{
+			ExecPath:      cfg.ChildExecPath,
+			ExecArgs:      cfg.ChildExecArgs,
+			WorkDir:       cfg.ChildWorkDir,
+			Env:           cfg.ChildEnv,
+			EnvFiles:      cfg.ChildEnvFiles,
+			InheritEnv:    cfg.InheritEnv,
+			RestartPolicy: cfg.RestartPolicy,
+			CrashLoop:     cfg.CrashLoop,
+			Stop:          cfg.Stop,
+			HealthChecks:  cfg.HealthChecks,
+			Readiness:     cfg.Readiness,
+			Hooks:         cfg.Hooks,
+			Critical:      true,
+		}}, nil
+	}
+
+	byName := make(map[string]Child, len(cfg.Children))
+	for _, c := range cfg.Children {
+		byName[c.Name] = c
+	}
+
+	ordered := make([]Child, 0, len(cfg.Children))
+	state := make(map[string]int, len(cfg.Children))
+	const visiting, visited = 1, 2
+	var visit func(c Child, path []string) error
+	visit = func(c Child, path []string) error {
+		switch state[c.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Wrapf(ErrDependencyCycle, "%s", strings.Join(append(path, c.Name), " -> "))
+		}
+		state[c.Name] = visiting
+		for _, dep := range c.DependsOn {
+			depChild, ok := byName[dep]
+			if !ok {
+				return errors.Wrapf(ErrUnknownDependency, "%s depends on %q", c.Name, dep)
+			}
+			if err := visit(depChild, append(path, c.Name)); err != nil {
+				return err
+			}
+		}
+		state[c.Name] = visited
+		ordered = append(ordered, c)
+		return nil
+	}
+	for _, c := range cfg.Children {
+		if err := visit(c, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// New loads the configuration file at path with the overlays applied in order and interpolates its variables,
+// ${configDir} is the directory of the configuration file at path, see WindowsServiceConfig.Interpolate
+func New(path string, overlays ...string) (cfg WindowsServiceConfig, err error) {
+	cfg, err = Load(path, overlays...)
+	if err != nil {
+		return cfg, err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "can not resolve config file path %s", path)
+	}
+	if err := cfg.Interpolate(filepath.Dir(absPath)); err != nil {
+		return cfg, errors.Wrapf(err, "can not interpolate config file %s", path)
 	}
 
 	return cfg, nil
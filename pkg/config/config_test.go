@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChildConfigs(t *testing.T) {
+	cfg := WindowsServiceConfig{ChildExecPath: "server.exe", ChildExecArgs: []string{"-port", "8080"}}
+	children, err := cfg.ChildConfigs()
+	require.NoError(t, err)
+	require.Equal(t, []Child{{ExecPath: "server.exe", ExecArgs: []string{"-port", "8080"}, Critical: true}}, children)
+
+	cfg = WindowsServiceConfig{Children: []Child{
+		{Name: "scheduler", DependsOn: []string{"worker", "api"}},
+		{Name: "worker", DependsOn: []string{"api"}},
+		{Name: "api"},
+	}}
+	children, err = cfg.ChildConfigs()
+	require.NoError(t, err)
+	names := make([]string, 0, len(children))
+	for _, c := range children {
+		names = append(names, c.Name)
+	}
+	require.Equal(t, []string{"api", "worker", "scheduler"}, names)
+
+	cfg = WindowsServiceConfig{Children: []Child{{Name: "api", DependsOn: []string{"db"}}}}
+	_, err = cfg.ChildConfigs()
+	require.ErrorIs(t, err, ErrUnknownDependency)
+
+	cfg = WindowsServiceConfig{Children: []Child{
+		{Name: "api", DependsOn: []string{"worker"}},
+		{Name: "worker", DependsOn: []string{"api"}},
+	}}
+	_, err = cfg.ChildConfigs()
+	require.ErrorIs(t, err, ErrDependencyCycle)
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Changes returns the names of the fields that differ between prev and next as they appear in the configuration file.
+// Changes of a child are reported as "children[name].field", added and removed children as "children[name]".
+func Changes(prev, next WindowsServiceConfig) []string {
+	changes := changedFields("", prev, next, "children")
+
+	nextChildren := make(map[string]Child, len(next.Children))
+	for _, c := range next.Children {
+		nextChildren[c.Name] = c
+	}
+	prevNames := make(map[string]bool, len(prev.Children))
+	for _, c := range prev.Children {
+		prevNames[c.Name] = true
+		prefix := fmt.Sprintf("children[%s]", c.Name)
+		nextChild, ok := nextChildren[c.Name]
+		if !ok {
+			changes = append(changes, prefix)
+			continue
+		}
+		changes = append(changes, changedFields(prefix+".", c, nextChild)...)
+	}
+	for _, c := range next.Children {
+		if !prevNames[c.Name] {
+			changes = append(changes, fmt.Sprintf("children[%s]", c.Name))
+		}
+	}
+
+	return changes
+}
+
+// ChildChanges returns the names of the fields that differ between prev and next as they appear in a child of the configuration file
+func ChildChanges(prev, next Child) []string {
+	return changedFields("", prev, next)
+}
+
+func changedFields(prefix string, prev, next any, skip ...string) []string {
+	var changes []string
+	prevValue, nextValue := reflect.ValueOf(prev), reflect.ValueOf(next)
+	for i := 0; i < prevValue.NumField(); i++ {
+		field := prevValue.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || slices.Contains(skip, name) {
+			continue
+		}
+		if !reflect.DeepEqual(prevValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			changes = append(changes, prefix+name)
+		}
+	}
+
+	return changes
+}
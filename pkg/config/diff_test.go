@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanges(t *testing.T) {
+	prev := WindowsServiceConfig{
+		Name:             "service",
+		ChildExecArgs:    []string{"-port", "8080"},
+		LogFileMaxSizeMB: 10,
+		RestartPolicy:    RestartPolicy{Mode: "always"},
+		Children: []Child{
+			{Name: "api", ExecPath: "api.exe"},
+			{Name: "worker", ExecPath: "worker.exe"},
+		},
+	}
+	require.Empty(t, Changes(prev, prev))
+
+	next := prev
+	next.ChildExecArgs = []string{"-port", "9090"}
+	next.LogFileMaxSizeMB = 20
+	next.RestartPolicy.MaxAttempts = 3
+	next.Children = []Child{
+		{Name: "api", ExecPath: "api.exe", Env: map[string]string{"PORT": "8080"}},
+		{Name: "scheduler", ExecPath: "scheduler.exe"},
+	}
+	require.Equal(t, []string{
+		"childExecArgs",
+		"logFileMaxSizeMB",
+		"restartPolicy",
+		"children[api].env",
+		"children[worker]",
+		"children[scheduler]",
+	}, Changes(prev, next))
+
+	require.Equal(t, []string{"execPath", "critical"}, ChildChanges(
+		Child{Name: "api", ExecPath: "api.exe"},
+		Child{Name: "api", ExecPath: "api2.exe", Critical: true},
+	))
+}
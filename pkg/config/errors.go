@@ -0,0 +1,19 @@
+package config
+
+import "github.com/pkg/errors"
+
+var (
+	ErrRequired    = errors.New("is required")
+	ErrNotAbsolute = errors.New("must be an absolute path")
+	ErrNotExist    = errors.New("does not exist")
+	ErrNotFile     = errors.New("must be a file")
+	ErrNotDir      = errors.New("must be a directory")
+	ErrOutOfRange  = errors.New("is out of range")
+
+	ErrUnknownVariable = errors.New("unknown variable")
+
+	ErrDuplicateName     = errors.New("is used by another child")
+	ErrNotAllowed        = errors.New("must be empty when children are set")
+	ErrUnknownDependency = errors.New("unknown dependency")
+	ErrDependencyCycle   = errors.New("dependency cycle")
+)
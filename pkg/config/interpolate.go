@@ -0,0 +1,237 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/secret"
+)
+
+// varPattern matches ${name} references and the $${ escape of a literal ${
+var varPattern = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+// Interpolate expands variable references in paths, args and environment values of the config:
+//
+//	${exeDir}               directory of the running executable
+//	${configDir}            directory of the config file, empty configDir falls back to ${exeDir}
+//	${serviceName}          name of the service
+//	${env:NAME}             environment variable NAME, it must be set
+//	${env:NAME:-default}    environment variable NAME, or default if it is unset or empty
+//	${secret:name}          secret name of the secrets store
+//
+// $${ is kept as a literal ${. Unknown variables are reported as a *ValidationError.
+func (cfg *WindowsServiceConfig) Interpolate(configDir string) error {
+	i, err := newInterpolator(cfg, configDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		cfg.secretValues = i.secretValues
+	}()
+
+	i.path("parentExecPath", &cfg.ParentExecPath)
+	i.str("account", &cfg.Account)
+	i.str("password", &cfg.Password)
+	i.str("recovery.command", &cfg.Recovery.Command)
+	i.path("childExecPath", &cfg.ChildExecPath)
+	i.list("childExecArgs", cfg.ChildExecArgs)
+	i.path("childWorkDir", &cfg.ChildWorkDir)
+	i.env("childEnv", cfg.ChildEnv)
+	for n := range cfg.ChildEnvFiles {
+		i.path(fmt.Sprintf("childEnvFiles[%d]", n), &cfg.ChildEnvFiles[n])
+	}
+	i.path("logFilePath", &cfg.LogFilePath)
+	i.path("systemd.unitDir", &cfg.Systemd.UnitDir)
+	i.path("systemd.systemctlPath", &cfg.Systemd.SystemctlPath)
+
+	i.lifecycle("", cfg.HealthChecks, &cfg.Readiness, cfg.Hooks)
+
+	for n := range cfg.Children {
+		c := &cfg.Children[n]
+		prefix := fmt.Sprintf("children[%d].", n)
+		i.path(prefix+"execPath", &c.ExecPath)
+		i.list(prefix+"execArgs", c.ExecArgs)
+		i.path(prefix+"workDir", &c.WorkDir)
+		i.env(prefix+"env", c.Env)
+		for m := range c.EnvFiles {
+			i.path(fmt.Sprintf("%senvFiles[%d]", prefix, m), &c.EnvFiles[m])
+		}
+		i.path(prefix+"logFilePath", &c.LogFilePath)
+		i.lifecycle(prefix, c.HealthChecks, &c.Readiness, c.Hooks)
+	}
+
+	if len(i.errs.Errors) > 0 {
+		return i.errs
+	}
+
+	return nil
+}
+
+// lifecycle expands the health checks, readiness and hooks of a child, prefix is the JSON path of the child
+func (i *interpolator) lifecycle(prefix string, checks []HealthCheck, readiness *Readiness, hooks Hooks) {
+	for n := range checks {
+		c := &checks[n]
+		field := fmt.Sprintf("%shealthChecks[%d]", prefix, n)
+		i.str(field+".url", &c.URL)
+		i.str(field+".address", &c.Address)
+		i.list(field+".command", c.Command)
+	}
+	i.str(prefix+"readiness.url", &readiness.URL)
+	i.str(prefix+"readiness.address", &readiness.Address)
+	i.list(prefix+"readiness.command", readiness.Command)
+	i.path(prefix+"readiness.path", &readiness.Path)
+
+	for _, stage := range []struct {
+		name  string
+		hooks []Hook
+	}{
+		{"preStart", hooks.PreStart},
+		{"postStart", hooks.PostStart},
+		{"preStop", hooks.PreStop},
+		{"postStop", hooks.PostStop},
+	} {
+		for n, h := range stage.hooks {
+			field := fmt.Sprintf("%shooks.%s[%d]", prefix, stage.name, n)
+			i.list(field+".command", h.Command)
+			i.env(field+".env", h.Env)
+		}
+	}
+}
+
+type interpolator struct {
+	vars      map[string]string
+	errs      *ValidationError
+	configDir string
+	secrets   Secrets
+	// store is opened on the first ${secret:name} reference
+	store        *secret.Store
+	storeErr     error
+	secretValues []string
+}
+
+// newInterpolator returns an interpolator for cfg, its secrets section is expanded right away
+func newInterpolator(cfg *WindowsServiceConfig, configDir string) (*interpolator, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return nil, errors.Wrap(err, "can not resolve executable path")
+	}
+	exeDir := filepath.Dir(exePath)
+	if configDir == "" {
+		configDir = exeDir
+	}
+
+	i := &interpolator{
+		vars: map[string]string{
+			"exeDir":      filepath.ToSlash(exeDir),
+			"configDir":   filepath.ToSlash(configDir),
+			"serviceName": cfg.Name,
+		},
+		errs:      &ValidationError{},
+		configDir: configDir,
+	}
+	i.path("secrets.path", &cfg.Secrets.Path)
+	i.path("secrets.keyFile", &cfg.Secrets.KeyFile)
+	i.secrets = cfg.Secrets
+
+	return i, nil
+}
+
+func (i *interpolator) str(field string, s *string) {
+	expanded, err := i.expand(*s)
+	if err != nil {
+		i.errs.add(field, err)
+		return
+	}
+	*s = expanded
+}
+
+// path expands s and cleans the resulting path
+func (i *interpolator) path(field string, s *string) {
+	if *s == "" {
+		return
+	}
+	i.str(field, s)
+	*s = filepath.Clean(*s)
+}
+
+func (i *interpolator) list(field string, list []string) {
+	for n := range list {
+		i.str(fmt.Sprintf("%s[%d]", field, n), &list[n])
+	}
+}
+
+func (i *interpolator) env(field string, env map[string]string) {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		expanded, err := i.expand(env[k])
+		if err != nil {
+			i.errs.add(field+"."+k, err)
+			continue
+		}
+		env[k] = expanded
+	}
+}
+
+func (i *interpolator) expand(s string) (string, error) {
+	var firstErr error
+	expanded := varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		value, err := i.lookup(match[2 : len(match)-1])
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return value
+	})
+
+	return expanded, firstErr
+}
+
+func (i *interpolator) lookup(name string) (string, error) {
+	if secretName, isSecret := strings.CutPrefix(name, "secret:"); isSecret {
+		store, err := i.openSecrets()
+		if err != nil {
+			return "", errors.Wrapf(err, "${%s}", name)
+		}
+		value, err := store.Get(secretName)
+		if err != nil {
+			return "", errors.Wrapf(err, "${%s}", name)
+		}
+		i.secretValues = append(i.secretValues, value)
+		return value, nil
+	}
+
+	envName, isEnv := strings.CutPrefix(name, "env:")
+	if !isEnv {
+		value, ok := i.vars[name]
+		if !ok {
+			return "", errors.Wrapf(ErrUnknownVariable, "${%s}", name)
+		}
+		return value, nil
+	}
+
+	envName, fallback, hasFallback := strings.Cut(envName, ":-")
+	if value := os.Getenv(envName); value != "" {
+		return value, nil
+	}
+	if hasFallback {
+		return fallback, nil
+	}
+	if _, ok := os.LookupEnv(envName); ok {
+		return "", nil
+	}
+
+	return "", errors.Wrapf(ErrUnknownVariable, "${%s} is not set", name)
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	exePath, err := os.Executable()
+	require.NoError(t, err)
+	exeDir := filepath.ToSlash(filepath.Dir(exePath))
+	t.Setenv("WINSVC_HOST", "db.local")
+	t.Setenv("WINSVC_EMPTY", "")
+
+	cfg := WindowsServiceConfig{
+		Name:           "api",
+		ParentExecPath: "${exeDir}/service.exe",
+		ChildExecPath:  "${configDir}/bin/../server.exe",
+		ChildExecArgs:  []string{"-name", "${serviceName}", "-port", "${env:WINSVC_PORT:-8080}", "-cost", "$${literal}"},
+		ChildEnv:       map[string]string{"DB_HOST": "${env:WINSVC_HOST}", "EMPTY": "${env:WINSVC_EMPTY}"},
+		LogFilePath:    "${serviceName}.log",
+		Readiness:      Readiness{Address: "localhost:${env:WINSVC_PORT:-8080}"},
+	}
+	require.NoError(t, cfg.Interpolate("/etc/api"))
+
+	require.Equal(t, filepath.Clean(exeDir+"/service.exe"), cfg.ParentExecPath)
+	require.Equal(t, filepath.Clean("/etc/api/server.exe"), cfg.ChildExecPath)
+	require.Equal(t, []string{"-name", "api", "-port", "8080", "-cost", "${literal}"}, cfg.ChildExecArgs)
+	require.Equal(t, map[string]string{"DB_HOST": "db.local", "EMPTY": ""}, cfg.ChildEnv)
+	require.Equal(t, "api.log", cfg.LogFilePath)
+	require.Equal(t, "localhost:8080", cfg.Readiness.Address)
+}
+
+func TestInterpolateUnknownVariables(t *testing.T) {
+	cfg := WindowsServiceConfig{
+		ChildExecPath: "${binDir}/server.exe",
+		ChildExecArgs: []string{"-token", "${env:WINSVC_UNSET_TOKEN}"},
+		Hooks:         Hooks{PreStart: []Hook{{Command: []string{"${exeDir}/migrate", "${nope}"}}}},
+	}
+	err := cfg.Interpolate("")
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	require.Len(t, validationErr.Errors, 3)
+	require.Equal(t, "childExecPath", validationErr.Errors[0].Field)
+	require.Equal(t, "childExecArgs[1]", validationErr.Errors[1].Field)
+	require.Equal(t, "hooks.preStart[0].command[1]", validationErr.Errors[2].Field)
+	require.ErrorIs(t, err, ErrUnknownVariable)
+	require.Contains(t, err.Error(), "${binDir}")
+}
+
+func TestNewInterpolatesConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "service.config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"name": "svc", "childExecPath": "${configDir}/server.exe"}`), 0o644))
+
+	cfg, err := New(path)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "server.exe"), cfg.ChildExecPath)
+}
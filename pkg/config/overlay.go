@@ -0,0 +1,84 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jinzhu/configor"
+	"github.com/pkg/errors"
+)
+
+// ProfileEnvVar is the environment variable selecting the profile of the configuration file
+const ProfileEnvVar = "SERVICE_PROFILE"
+
+// ProfilePath returns the overlay of the profile next to the configuration file at path,
+// "service.config.prod.json" for the "prod" profile of "service.config.json"
+func ProfilePath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// Load loads the configuration file at path and applies the overlays in order without interpolating variables.
+// Objects of an overlay are merged into the configuration, other values including lists replace it,
+// a null removes a value.
+func Load(path string, overlays ...string) (cfg WindowsServiceConfig, err error) {
+	if err := configor.Load(&cfg, path); err != nil {
+		return cfg, errors.Wrapf(err, "can not parse config file %s", path)
+	}
+	if len(overlays) == 0 {
+		return cfg, nil
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "can not encode config file %s", path)
+	}
+	var merged map[string]any
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return cfg, errors.Wrapf(err, "can not encode config file %s", path)
+	}
+
+	for _, overlay := range overlays {
+		data, err := os.ReadFile(overlay)
+		if err != nil {
+			return cfg, errors.Wrapf(err, "can not read config overlay %s", overlay)
+		}
+		var values map[string]any
+		if err := json.Unmarshal(data, &values); err != nil {
+			return cfg, errors.Wrapf(err, "can not parse config overlay %s", overlay)
+		}
+		merge(merged, values)
+	}
+
+	if data, err = json.Marshal(merged); err != nil {
+		return cfg, errors.Wrapf(err, "can not encode config file %s", path)
+	}
+	cfg = WindowsServiceConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return cfg, errors.Wrapf(err, "can not apply config overlays %s", strings.Join(overlays, ", "))
+	}
+
+	return cfg, nil
+}
+
+// merge deep-merges the objects of overlay into base, any other overlay value replaces the base one
+func merge(base, overlay map[string]any) {
+	for key, value := range overlay {
+		if value == nil {
+			delete(base, key)
+			continue
+		}
+		baseObject, baseOk := base[key].(map[string]any)
+		object, ok := value.(map[string]any)
+		if baseOk && ok {
+			merge(baseObject, object)
+			continue
+		}
+		base[key] = value
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfilePath(t *testing.T) {
+	require.Equal(t, filepath.Join("dir", "service.config.prod.json"), ProfilePath(filepath.Join("dir", "service.config.json"), "prod"))
+}
+
+func TestLoadOverlays(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "service.config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"name": "svc",
+		"childExecPath": "${configDir}/server.exe",
+		"childExecArgs": ["-port", "8080"],
+		"childEnv": {"DB_HOST": "localhost", "DEBUG": "1"},
+		"restartPolicy": {"mode": "always", "maxAttempts": 3},
+		"children": [{"name": "api", "execPath": "api.exe", "env": {"A": "1"}}]
+	}`), 0o644))
+	prod := ProfilePath(path, "prod")
+	require.NoError(t, os.WriteFile(prod, []byte(`{
+		"childExecArgs": ["-port", "80"],
+		"childEnv": {"DB_HOST": "db.prod", "DEBUG": null},
+		"restartPolicy": {"maxAttempts": 10},
+		"children": [{"name": "worker", "execPath": "worker.exe"}]
+	}`), 0o644))
+	local := filepath.Join(dir, "local.json")
+	require.NoError(t, os.WriteFile(local, []byte(`{"logFileMaxSizeMB": 5}`), 0o644))
+
+	cfg, err := Load(path, prod, local)
+	require.NoError(t, err)
+	require.Equal(t, "svc", cfg.Name)
+	require.Equal(t, "${configDir}/server.exe", cfg.ChildExecPath)
+	require.Equal(t, []string{"-port", "80"}, cfg.ChildExecArgs)
+	require.Equal(t, map[string]string{"DB_HOST": "db.prod"}, cfg.ChildEnv)
+	require.Equal(t, RestartPolicy{Mode: "always", MaxAttempts: 10}, cfg.RestartPolicy)
+	require.Equal(t, []Child{{Name: "worker", ExecPath: "worker.exe"}}, cfg.Children)
+	require.Equal(t, 5, cfg.LogFileMaxSizeMB)
+
+	cfg, err = New(path, prod)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "server.exe"), cfg.ChildExecPath)
+
+	require.NoError(t, os.WriteFile(local, []byte(`{"logFileMaxSize": 5}`), 0o644))
+	_, err = Load(path, local)
+	require.ErrorContains(t, err, "logFileMaxSize")
+
+	_, err = Load(path, filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/secret"
+)
+
+// PassphraseEnvVar is the environment variable holding the passphrase of the secrets store without a key file
+const PassphraseEnvVar = "SERVICE_SECRETS_PASSPHRASE"
+
+const defaultSecretsPath = "secrets.json"
+
+// OpenSecrets opens the secrets store of the configuration file at path with the overlays applied,
+// the ${secret:name} references of the configuration are not resolved so missing secrets can be set
+func OpenSecrets(path string, overlays ...string) (*secret.Store, error) {
+	cfg, err := Load(path, overlays...)
+	if err != nil {
+		return nil, err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not resolve config file path %s", path)
+	}
+
+	i, err := newInterpolator(&cfg, filepath.Dir(absPath))
+	if err != nil {
+		return nil, err
+	}
+	if len(i.errs.Errors) > 0 {
+		return nil, i.errs
+	}
+
+	return i.openSecrets()
+}
+
+// Redact replaces the values of the secrets referenced by the configuration in s with a mask
+func (cfg WindowsServiceConfig) Redact(s string) string {
+	return secret.Redact(s, cfg.secretValues)
+}
+
+// openSecrets opens the secrets store once, relative paths resolve against the directory of the configuration file
+func (i *interpolator) openSecrets() (*secret.Store, error) {
+	if i.store != nil || i.storeErr != nil {
+		return i.store, i.storeErr
+	}
+
+	path := i.secrets.Path
+	if path == "" {
+		path = defaultSecretsPath
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(i.configDir, path)
+	}
+
+	var key []byte
+	if i.secrets.KeyFile != "" {
+		keyFile := i.secrets.KeyFile
+		if !filepath.IsAbs(keyFile) {
+			keyFile = filepath.Join(i.configDir, keyFile)
+		}
+		key, i.storeErr = secret.ReadKeyFile(keyFile)
+	} else if passphrase := os.Getenv(PassphraseEnvVar); passphrase != "" {
+		key = []byte(passphrase)
+	} else {
+		i.storeErr = errors.Wrapf(secret.ErrNoKey, "set secrets.keyFile or %s", PassphraseEnvVar)
+	}
+	if i.storeErr == nil {
+		i.store, i.storeErr = secret.Open(path, key)
+	}
+
+	return i.store, i.storeErr
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/secret"
+)
+
+func TestInterpolateSecrets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "service.config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"name": "svc",
+		"childExecArgs": ["-db-password", "${secret:db_password}"],
+		"childEnv": {"API_TOKEN": "${secret:api_token}"},
+		"secrets": {"path": "${configDir}/svc.secrets", "keyFile": "svc.key"}
+	}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "svc.key"), []byte("key material\n"), 0o600))
+
+	_, err := New(path)
+	require.ErrorIs(t, err, secret.ErrSecretNotSet)
+
+	store, err := OpenSecrets(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Set("db_password", "s3cr3t"))
+	require.NoError(t, store.Set("api_token", "t0k3n"))
+	require.NoError(t, store.Save())
+	_, err = os.Stat(filepath.Join(dir, "svc.secrets"))
+	require.NoError(t, err)
+
+	cfg, err := New(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"-db-password", "s3cr3t"}, cfg.ChildExecArgs)
+	require.Equal(t, map[string]string{"API_TOKEN": "t0k3n"}, cfg.ChildEnv)
+	require.Equal(t, "-db-password **** API_TOKEN=****", cfg.Redact("-db-password s3cr3t API_TOKEN=t0k3n"))
+}
+
+func TestInterpolateSecretsWithoutKey(t *testing.T) {
+	t.Setenv(PassphraseEnvVar, "")
+	cfg := WindowsServiceConfig{ChildExecArgs: []string{"${secret:db_password}"}}
+
+	require.ErrorIs(t, cfg.Interpolate(t.TempDir()), secret.ErrNoKey)
+}
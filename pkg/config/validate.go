@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FieldError is a problem with a single config field, Field is its JSON path
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError holds every problem found in the config
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+func (e *ValidationError) add(field string, err error) {
+	e.Errors = append(e.Errors, &FieldError{Field: field, Err: err})
+}
+
+// Validate checks the required fields, paths and numeric log settings of the config
+// and returns all problems at once as a *ValidationError, the settings parsed by the other packages
+// are checked by service.Validate
+func (cfg WindowsServiceConfig) Validate() error {
+	v := &ValidationError{}
+
+	if cfg.Name == "" {
+		v.add("name", ErrRequired)
+	}
+	if cfg.Description == "" {
+		v.add("description", ErrRequired)
+	}
+	v.checkPath("parentExecPath", cfg.ParentExecPath, false)
+	if len(cfg.Children) == 0 {
+		v.checkChild("", Child{ExecPath: cfg.ChildExecPath, WorkDir: cfg.ChildWorkDir, EnvFiles: cfg.ChildEnvFiles})
+	} else {
+		v.checkChildren(cfg)
+	}
+
+	v.checkRange("logFileMaxSizeMB", cfg.LogFileMaxSizeMB)
+	v.checkRange("logFileMaxBackups", cfg.LogFileMaxBackups)
+	v.checkRange("logFileMaxAgeDays", cfg.LogFileMaxAgeDays)
+	if cfg.Systemd.UnitDir != "" && !filepath.IsAbs(cfg.Systemd.UnitDir) {
+		v.add("systemd.unitDir", errors.Wrapf(ErrNotAbsolute, "%q", cfg.Systemd.UnitDir))
+	}
+
+	if len(v.Errors) > 0 {
+		return v
+	}
+
+	return nil
+}
+
+// checkChild checks the paths of a child, prefix is its JSON path, empty for the legacy child fields
+func (v *ValidationError) checkChild(prefix string, c Child) {
+	field := func(name string) string {
+		if prefix == "" {
+			return "child" + strings.ToUpper(name[:1]) + name[1:]
+		}
+		return prefix + "." + name
+	}
+
+	v.checkPath(field("execPath"), c.ExecPath, false)
+	if c.WorkDir != "" {
+		v.checkPath(field("workDir"), c.WorkDir, true)
+	}
+	for i, file := range c.EnvFiles {
+		if filepath.IsAbs(file) {
+			v.checkPath(fmt.Sprintf("%s[%d]", field("envFiles"), i), file, false)
+		}
+	}
+}
+
+func (v *ValidationError) checkChildren(cfg WindowsServiceConfig) {
+	for _, legacy := range []struct {
+		field string
+		empty bool
+	}{
+		{"childExecPath", cfg.ChildExecPath == ""},
+		{"childExecArgs", len(cfg.ChildExecArgs) == 0},
+		{"childWorkDir", cfg.ChildWorkDir == ""},
+		{"childEnv", len(cfg.ChildEnv) == 0},
+		{"childEnvFiles", len(cfg.ChildEnvFiles) == 0},
+		{"restartPolicy", reflect.ValueOf(cfg.RestartPolicy).IsZero()},
+		{"crashLoop", cfg.CrashLoop == CrashLoop{}},
+		{"stop", cfg.Stop == Stop{}},
+		{"healthChecks", len(cfg.HealthChecks) == 0},
+		{"readiness", reflect.ValueOf(cfg.Readiness).IsZero()},
+		{"hooks", reflect.ValueOf(cfg.Hooks).IsZero()},
+	} {
+		if !legacy.empty {
+			v.add(legacy.field, ErrNotAllowed)
+		}
+	}
+
+	names := make(map[string]bool, len(cfg.Children))
+	for i, c := range cfg.Children {
+		prefix := fmt.Sprintf("children[%d]", i)
+		switch {
+		case c.Name == "":
+			v.add(prefix+".name", ErrRequired)
+		case names[c.Name]:
+			v.add(prefix+".name", errors.Wrapf(ErrDuplicateName, "%q", c.Name))
+		}
+		names[c.Name] = true
+		v.checkChild(prefix, c)
+	}
+
+	if _, err := cfg.ChildConfigs(); err != nil {
+		v.add("children", err)
+	}
+}
+
+func (v *ValidationError) checkPath(field, path string, dir bool) {
+	if path == "" {
+		v.add(field, ErrRequired)
+		return
+	}
+	if !filepath.IsAbs(path) {
+		v.add(field, errors.Wrapf(ErrNotAbsolute, "%q", path))
+		return
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		v.add(field, errors.Wrapf(ErrNotExist, "%q", path))
+	case dir && !info.IsDir():
+		v.add(field, errors.Wrapf(ErrNotDir, "%q", path))
+	case !dir && !info.Mode().IsRegular():
+		v.add(field, errors.Wrapf(ErrNotFile, "%q", path))
+	}
+}
+
+func (v *ValidationError) checkRange(field string, value int) {
+	if value < 0 {
+		v.add(field, errors.Wrapf(ErrOutOfRange, "%d < 0", value))
+	}
+}
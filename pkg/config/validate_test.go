@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "service.exe")
+	require.NoError(t, os.WriteFile(exe, nil, 0o755))
+
+	cfg := WindowsServiceConfig{
+		Name:           "service",
+		Description:    "Windows service",
+		ParentExecPath: exe,
+		ChildExecPath:  exe,
+		ChildWorkDir:   dir,
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg = WindowsServiceConfig{
+		Description:      "Windows service",
+		ParentExecPath:   "service.exe",
+		ChildExecPath:    filepath.Join(dir, "missing.exe"),
+		ChildWorkDir:     exe,
+		LogFileMaxSizeMB: -1,
+	}
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+
+	fields := make(map[string]error)
+	for _, fieldErr := range validationErr.Errors {
+		fields[fieldErr.Field] = fieldErr
+	}
+	require.Len(t, fields, 5)
+	require.ErrorIs(t, fields["name"], ErrRequired)
+	require.ErrorIs(t, fields["parentExecPath"], ErrNotAbsolute)
+	require.ErrorIs(t, fields["childExecPath"], ErrNotExist)
+	require.ErrorIs(t, fields["childWorkDir"], ErrNotDir)
+	require.ErrorIs(t, fields["logFileMaxSizeMB"], ErrOutOfRange)
+
+	require.ErrorIs(t, err, ErrNotAbsolute)
+	require.Contains(t, err.Error(), `parentExecPath "service.exe": must be an absolute path`)
+}
+
+func TestValidateChildren(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "service.exe")
+	require.NoError(t, os.WriteFile(exe, nil, 0o755))
+
+	cfg := WindowsServiceConfig{
+		Name:           "service",
+		Description:    "Windows service",
+		ParentExecPath: exe,
+		Children:       []Child{{Name: "web", ExecPath: exe}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.ChildExecArgs = []string{"-port", "80"}
+	cfg.RestartPolicy = RestartPolicy{Mode: "always"}
+	cfg.CrashLoop = CrashLoop{MaxRestarts: 5}
+	cfg.Stop = Stop{TimeoutMs: 1000}
+	cfg.Readiness = Readiness{Type: "tcp", Address: "localhost:80"}
+	cfg.Hooks = Hooks{PreStart: []Hook{{Command: []string{"migrate"}}}}
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+
+	var fields []string
+	for _, fieldErr := range validationErr.Errors {
+		require.ErrorIs(t, fieldErr, ErrNotAllowed)
+		fields = append(fields, fieldErr.Field)
+	}
+	require.Equal(t, []string{"childExecArgs", "restartPolicy", "crashLoop", "stop", "readiness", "hooks"}, fields)
+}
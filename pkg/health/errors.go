@@ -0,0 +1,13 @@
+package health
+
+import "github.com/pkg/errors"
+
+var (
+	ErrInvalidType       = errors.New("invalid health check type")
+	ErrMissingTarget     = errors.New("health check target is required")
+	ErrInvalidInterval   = errors.New("health check intervals and timeouts must not be negative")
+	ErrInvalidThreshold  = errors.New("health check failure threshold must not be negative")
+	ErrUnexpectedStatus  = errors.New("unexpected status code")
+	ErrNotReady          = errors.New("process is not ready")
+	ErrExitedBeforeReady = errors.New("process exited before it was ready")
+)
@@ -0,0 +1,167 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+const (
+	defaultInterval         = 10 * time.Second
+	defaultTimeout          = time.Second
+	defaultFailureThreshold = 3
+)
+
+// Check is the parsed form of config.HealthCheck with defaults applied
+type Check struct {
+	Name             string
+	Probe            Probe
+	InitialDelay     time.Duration
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int
+}
+
+// NewChecks parses the health checks section of the service config
+func NewChecks(cfgs []config.HealthCheck) ([]Check, error) {
+	checks := make([]Check, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		c, err := newCheck(cfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "health check #%d", i)
+		}
+		checks = append(checks, c)
+	}
+
+	return checks, nil
+}
+
+func newCheck(cfg config.HealthCheck) (Check, error) {
+	c := Check{
+		Name:             cfg.Name,
+		InitialDelay:     time.Duration(cfg.InitialDelayMs) * time.Millisecond,
+		Interval:         time.Duration(cfg.IntervalMs) * time.Millisecond,
+		Timeout:          time.Duration(cfg.TimeoutMs) * time.Millisecond,
+		FailureThreshold: cfg.FailureThreshold,
+	}
+
+	var target string
+	switch cfg.Type {
+	case "http":
+		c.Probe, target = HTTPProbe{URL: cfg.URL}, cfg.URL
+	case "tcp":
+		c.Probe, target = TCPProbe{Address: cfg.Address}, cfg.Address
+	case "exec":
+		c.Probe, target = ExecProbe{Command: cfg.Command}, strings.Join(cfg.Command, " ")
+	default:
+		return c, errors.Wrapf(ErrInvalidType, "%q", cfg.Type)
+	}
+	if target == "" {
+		return c, errors.Wrap(ErrMissingTarget, cfg.Type)
+	}
+	if c.Name == "" {
+		c.Name = cfg.Type + " " + target
+	}
+
+	if c.InitialDelay < 0 || c.Interval < 0 || c.Timeout < 0 {
+		return c, ErrInvalidInterval
+	}
+	if c.FailureThreshold < 0 {
+		return c, ErrInvalidThreshold
+	}
+	if c.Interval == 0 {
+		c.Interval = defaultInterval
+	}
+	if c.InitialDelay == 0 {
+		c.InitialDelay = c.Interval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaultFailureThreshold
+	}
+
+	return c, nil
+}
+
+// Monitor runs health checks against a running child process
+type Monitor struct {
+	checks []Check
+	clock  clock.Clock
+	log    io.Writer
+}
+
+func NewMonitor(checks []Check, clk clock.Clock, log io.Writer) *Monitor {
+	return &Monitor{
+		checks: checks,
+		clock:  clk,
+		log:    log,
+	}
+}
+
+// Run probes every check at its interval until ctx is done. The first check reaching
+// its failure threshold is reported on unhealthy, after which Run stops probing.
+func (m *Monitor) Run(ctx context.Context, unhealthy chan<- error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	failed := make(chan error)
+	for _, c := range m.checks {
+		go m.watch(ctx, c, failed)
+	}
+
+	select {
+	case err := <-failed:
+		select {
+		case unhealthy <- err:
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+	}
+}
+
+func (m *Monitor) watch(ctx context.Context, c Check, failed chan<- error) {
+	wait := c.InitialDelay
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.clock.After(wait):
+		}
+		wait = c.Interval
+
+		checkCtx, cancel := context.WithTimeout(ctx, c.Timeout)
+		err := c.Probe.Check(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			if failures > 0 {
+				m.log.Write([]byte(fmt.Sprintf("Health check %s passed after %d failures\n", c.Name, failures)))
+			}
+			failures = 0
+			continue
+		}
+
+		failures++
+		m.log.Write([]byte(fmt.Sprintf("Health check %s failed (%d/%d): %s\n", c.Name, failures, c.FailureThreshold, err.Error())))
+		if failures >= c.FailureThreshold {
+			select {
+			case failed <- errors.Wrapf(err, "health check %s failed %d times", c.Name, failures):
+			case <-ctx.Done():
+			}
+			return
+		}
+	}
+}
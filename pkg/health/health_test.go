@@ -0,0 +1,140 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+type probeFunc func(ctx context.Context) error
+
+func (f probeFunc) Check(ctx context.Context) error { return f(ctx) }
+
+func TestNewChecks(t *testing.T) {
+	checks, err := NewChecks([]config.HealthCheck{{Type: "http", URL: "http://localhost:8080/hello"}})
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+	require.Equal(t, "http http://localhost:8080/hello", checks[0].Name)
+	require.Equal(t, defaultInterval, checks[0].Interval)
+	require.Equal(t, defaultInterval, checks[0].InitialDelay)
+	require.Equal(t, defaultTimeout, checks[0].Timeout)
+	require.Equal(t, defaultFailureThreshold, checks[0].FailureThreshold)
+
+	_, err = NewChecks([]config.HealthCheck{{Type: "udp", Address: "localhost:53"}})
+	require.ErrorIs(t, err, ErrInvalidType)
+	_, err = NewChecks([]config.HealthCheck{{Type: "tcp"}})
+	require.ErrorIs(t, err, ErrMissingTarget)
+	_, err = NewChecks([]config.HealthCheck{{Type: "exec", Command: []string{"true"}, FailureThreshold: -1}})
+	require.ErrorIs(t, err, ErrInvalidThreshold)
+}
+
+func TestHTTPProbe(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	probe := HTTPProbe{URL: srv.URL + "/hello"}
+	require.NoError(t, probe.Check(context.Background()))
+
+	status = http.StatusServiceUnavailable
+	require.ErrorIs(t, probe.Check(context.Background()), ErrUnexpectedStatus)
+}
+
+func TestTCPProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	probe := TCPProbe{Address: ln.Addr().String()}
+	require.NoError(t, probe.Check(context.Background()))
+
+	require.NoError(t, ln.Close())
+	require.Error(t, probe.Check(context.Background()))
+}
+
+func TestExecProbe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on the true and false commands")
+	}
+	require.NoError(t, ExecProbe{Command: []string{"true"}}.Check(context.Background()))
+	require.Error(t, ExecProbe{Command: []string{"false"}}.Check(context.Background()))
+}
+
+// tick advances clk by d once a probe is waiting on it
+func tick(t *testing.T, clk *clock.Fake, d time.Duration) {
+	require.Eventually(t, func() bool { return clk.Waiters() == 1 }, time.Second, time.Millisecond)
+	clk.Advance(d)
+}
+
+func TestMonitorReportsAfterThreshold(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	var calls atomic.Int32
+	healthy := atomic.Bool{}
+	healthy.Store(true)
+	probe := probeFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		if healthy.Load() {
+			return nil
+		}
+		return context.DeadlineExceeded
+	})
+	check := Check{Name: "fake", Probe: probe, InitialDelay: time.Second, Interval: time.Second, Timeout: time.Second, FailureThreshold: 2}
+	var log bytes.Buffer
+	m := NewMonitor([]Check{check}, clk, &log)
+
+	unhealthy := make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx, unhealthy)
+
+	tick(t, clk, time.Second)
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	healthy.Store(false)
+	tick(t, clk, time.Second)
+	select {
+	case <-unhealthy:
+		t.Fatal("reported before reaching the failure threshold")
+	case <-time.After(10 * time.Millisecond):
+	}
+	tick(t, clk, time.Second)
+
+	select {
+	case err := <-unhealthy:
+		require.Contains(t, err.Error(), "health check fake failed 2 times")
+	case <-time.After(time.Second):
+		t.Fatal("unhealthy child was not reported")
+	}
+	require.Equal(t, int32(3), calls.Load())
+	require.Contains(t, log.String(), "Health check fake failed (2/2)")
+}
+
+func TestMonitorStopsOnCancel(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	check := Check{Name: "fake", Probe: probeFunc(func(ctx context.Context) error { return nil }), InitialDelay: time.Second, Interval: time.Second, Timeout: time.Second, FailureThreshold: 1}
+	m := NewMonitor([]Check{check}, clk, &bytes.Buffer{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, make(chan error))
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("monitor did not stop")
+	}
+}
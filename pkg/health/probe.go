@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// Probe checks once whether the child process is healthy
+type Probe interface {
+	Check(ctx context.Context) error
+}
+
+// HTTPProbe passes when a GET request to URL returns a 2xx or 3xx status
+type HTTPProbe struct {
+	URL string
+}
+
+func (p HTTPProbe) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return errors.Wrapf(ErrUnexpectedStatus, "%d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// TCPProbe passes when a connection to Address can be established
+type TCPProbe struct {
+	Address string
+}
+
+func (p TCPProbe) Check(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// ExecProbe passes when Command exits with code 0
+type ExecProbe struct {
+	Command []string
+}
+
+func (p ExecProbe) Check(ctx context.Context) error {
+	return exec.CommandContext(ctx, p.Command[0], p.Command[1:]...).Run()
+}
+
+// FileProbe passes when a file exists at Path
+type FileProbe struct {
+	Path string
+}
+
+func (p FileProbe) Check(ctx context.Context) error {
+	_, err := os.Stat(p.Path)
+	return err
+}
@@ -0,0 +1,152 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+const (
+	defaultReadinessInterval = 500 * time.Millisecond
+	defaultStartTimeout      = 30 * time.Second
+	defaultReadyMessage      = "READY=1"
+)
+
+// Gate is the parsed form of config.Readiness with defaults applied.
+// Either Probe is polled or Message is awaited in the child output.
+type Gate struct {
+	Probe        Probe
+	Message      string
+	Interval     time.Duration
+	Timeout      time.Duration
+	StartTimeout time.Duration
+}
+
+// NewGate parses the readiness section of the service config, it returns nil if gating is disabled
+func NewGate(cfg config.Readiness) (*Gate, error) {
+	if cfg.Type == "" {
+		return nil, nil
+	}
+
+	g := &Gate{
+		Interval:     time.Duration(cfg.IntervalMs) * time.Millisecond,
+		Timeout:      time.Duration(cfg.TimeoutMs) * time.Millisecond,
+		StartTimeout: time.Duration(cfg.StartTimeoutMs) * time.Millisecond,
+	}
+
+	var target string
+	switch cfg.Type {
+	case "file":
+		g.Probe, target = FileProbe{Path: cfg.Path}, cfg.Path
+	case "notify":
+		g.Message, target = cfg.Message, "notify"
+		if g.Message == "" {
+			g.Message = defaultReadyMessage
+		}
+	default:
+		c, err := newCheck(config.HealthCheck{Type: cfg.Type, URL: cfg.URL, Address: cfg.Address, Command: cfg.Command})
+		if err != nil {
+			return nil, errors.Wrap(err, "readiness")
+		}
+		g.Probe, target = c.Probe, c.Name
+	}
+	if target == "" {
+		return nil, errors.Wrapf(ErrMissingTarget, "readiness %s", cfg.Type)
+	}
+
+	if g.Interval < 0 || g.Timeout < 0 || g.StartTimeout < 0 {
+		return nil, ErrInvalidInterval
+	}
+	if g.Interval == 0 {
+		g.Interval = defaultReadinessInterval
+	}
+	if g.Timeout == 0 {
+		g.Timeout = defaultTimeout
+	}
+	if g.StartTimeout == 0 {
+		g.StartTimeout = defaultStartTimeout
+	}
+
+	return g, nil
+}
+
+// Wait blocks until the child process is ready. It polls the probe, or waits on notified
+// for notify gates, calling progress with an increasing checkpoint on every attempt.
+// It fails with ErrExitedBeforeReady if the child exits on exited, and with ErrNotReady after StartTimeout.
+func (g *Gate) Wait(clk clock.Clock, notified <-chan struct{}, exited <-chan error, progress func(checkpoint uint32)) error {
+	deadline := clk.After(g.StartTimeout)
+	var checkpoint uint32
+	for {
+		checkpoint++
+		progress(checkpoint)
+
+		if g.Probe != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), g.Timeout)
+			err := g.Probe.Check(ctx)
+			cancel()
+			if err == nil {
+				return nil
+			}
+		}
+
+		select {
+		case <-notified:
+			return nil
+		case err := <-exited:
+			if err == nil {
+				return errors.Wrap(ErrExitedBeforeReady, "exit status 0")
+			}
+			return errors.Wrap(ErrExitedBeforeReady, err.Error())
+		case <-deadline:
+			return errors.Wrapf(ErrNotReady, "within %s", g.StartTimeout)
+		case <-clk.After(g.Interval):
+		}
+	}
+}
+
+// NewNotifyWriter wraps w and closes the returned channel once message is written through it
+func NewNotifyWriter(w io.Writer, message string) (io.Writer, <-chan struct{}) {
+	nw := &notifyWriter{
+		w:        w,
+		message:  []byte(message),
+		notified: make(chan struct{}),
+	}
+
+	return nw, nw.notified
+}
+
+type notifyWriter struct {
+	w        io.Writer
+	message  []byte
+	mu       sync.Mutex
+	tail     []byte
+	notified chan struct{}
+	done     bool
+}
+
+func (nw *notifyWriter) Write(p []byte) (int, error) {
+	nw.mu.Lock()
+	if !nw.done {
+		// keep the end of the previous write in case the message is split across writes
+		buf := append(nw.tail, p...)
+		if bytes.Contains(buf, nw.message) {
+			nw.done = true
+			nw.tail = nil
+			close(nw.notified)
+		} else if keep := len(nw.message) - 1; len(buf) > keep {
+			nw.tail = append(nw.tail[:0], buf[len(buf)-keep:]...)
+		} else {
+			nw.tail = buf
+		}
+	}
+	nw.mu.Unlock()
+
+	return nw.w.Write(p)
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestNewGate(t *testing.T) {
+	g, err := NewGate(config.Readiness{})
+	require.NoError(t, err)
+	require.Nil(t, g)
+
+	g, err = NewGate(config.Readiness{Type: "notify"})
+	require.NoError(t, err)
+	require.Equal(t, defaultReadyMessage, g.Message)
+	require.Equal(t, defaultStartTimeout, g.StartTimeout)
+
+	g, err = NewGate(config.Readiness{Type: "tcp", Address: "localhost:8080"})
+	require.NoError(t, err)
+	require.Equal(t, TCPProbe{Address: "localhost:8080"}, g.Probe)
+
+	_, err = NewGate(config.Readiness{Type: "file"})
+	require.ErrorIs(t, err, ErrMissingTarget)
+}
+
+func TestGateWaitsForFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ready")
+	g := &Gate{Probe: FileProbe{Path: path}, Interval: 10 * time.Millisecond, Timeout: time.Second, StartTimeout: 5 * time.Second}
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		os.WriteFile(path, nil, 0o644)
+	})
+	var checkpoints []uint32
+	require.NoError(t, g.Wait(clock.System{}, nil, nil, func(checkpoint uint32) {
+		checkpoints = append(checkpoints, checkpoint)
+	}))
+	require.Greater(t, len(checkpoints), 1)
+	for i, checkpoint := range checkpoints {
+		require.Equal(t, uint32(i+1), checkpoint)
+	}
+}
+
+func TestGateTimesOut(t *testing.T) {
+	g := &Gate{Probe: FileProbe{Path: filepath.Join(t.TempDir(), "ready")}, Interval: 10 * time.Millisecond, Timeout: time.Second, StartTimeout: 100 * time.Millisecond}
+
+	err := g.Wait(clock.System{}, nil, nil, func(uint32) {})
+	require.ErrorIs(t, err, ErrNotReady)
+}
+
+func TestGateFailsWhenProcessExits(t *testing.T) {
+	g := &Gate{Message: defaultReadyMessage, Interval: time.Hour, Timeout: time.Second, StartTimeout: time.Hour}
+	exited := make(chan error, 1)
+	exited <- errors.New("exit status 1")
+
+	err := g.Wait(clock.System{}, nil, exited, func(uint32) {})
+	require.ErrorIs(t, err, ErrExitedBeforeReady)
+	require.Contains(t, err.Error(), "exit status 1")
+}
+
+func TestNotifyWriter(t *testing.T) {
+	var out bytes.Buffer
+	w, notified := NewNotifyWriter(&out, "READY=1")
+	g := &Gate{Message: "READY=1", Interval: time.Hour, Timeout: time.Second, StartTimeout: time.Hour}
+
+	w.Write([]byte("starting\nREA"))
+	select {
+	case <-notified:
+		t.Fatal("notified before the message was complete")
+	default:
+	}
+	w.Write([]byte("DY=1\n"))
+	w.Write([]byte("READY=1\n"))
+
+	require.NoError(t, g.Wait(clock.System{}, notified, nil, func(uint32) {}))
+	require.Equal(t, "starting\nREADY=1\nREADY=1\n", out.String())
+}
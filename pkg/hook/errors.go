@@ -0,0 +1,10 @@
+package hook
+
+import "github.com/pkg/errors"
+
+var (
+	ErrMissingCommand   = errors.New("hook command is required")
+	ErrInvalidTimeout   = errors.New("hook timeout must not be negative")
+	ErrInvalidOnFailure = errors.New("invalid hook failure policy")
+	ErrHookFailed       = errors.New("hook failed")
+)
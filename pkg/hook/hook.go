@@ -0,0 +1,145 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+type Stage string
+
+const (
+	StagePreStart  Stage = "pre-start"
+	StagePostStart Stage = "post-start"
+	StagePreStop   Stage = "pre-stop"
+	StagePostStop  Stage = "post-stop"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Hook is the parsed form of config.Hook with defaults applied
+type Hook struct {
+	Name    string
+	Command []string
+	Timeout time.Duration
+	Env     []string
+	// Abort stops running the remaining hooks of the stage and fails it
+	Abort bool
+}
+
+// Hooks are the parsed hooks of every stage
+type Hooks map[Stage][]Hook
+
+// New parses the hooks section of the service config
+func New(cfg config.Hooks) (Hooks, error) {
+	hooks := make(Hooks)
+	for stage, cfgs := range map[Stage][]config.Hook{
+		StagePreStart:  cfg.PreStart,
+		StagePostStart: cfg.PostStart,
+		StagePreStop:   cfg.PreStop,
+		StagePostStop:  cfg.PostStop,
+	} {
+		for i, c := range cfgs {
+			h, err := newHook(c)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s hook #%d", stage, i)
+			}
+			hooks[stage] = append(hooks[stage], h)
+		}
+	}
+
+	return hooks, nil
+}
+
+func newHook(cfg config.Hook) (Hook, error) {
+	h := Hook{
+		Name:    cfg.Name,
+		Command: cfg.Command,
+		Timeout: time.Duration(cfg.TimeoutMs) * time.Millisecond,
+	}
+
+	if len(h.Command) == 0 || h.Command[0] == "" {
+		return h, ErrMissingCommand
+	}
+	if h.Name == "" {
+		h.Name = strings.Join(h.Command, " ")
+	}
+	if h.Timeout < 0 {
+		return h, ErrInvalidTimeout
+	}
+	if h.Timeout == 0 {
+		h.Timeout = defaultTimeout
+	}
+	switch cfg.OnFailure {
+	case "", "abort":
+		h.Abort = true
+	case "continue":
+	default:
+		return h, errors.Wrapf(ErrInvalidOnFailure, "%q", cfg.OnFailure)
+	}
+
+	keys := make([]string, 0, len(cfg.Env))
+	for k := range cfg.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.Env = append(h.Env, k+"="+cfg.Env[k])
+	}
+
+	return h, nil
+}
+
+// Run runs the hooks of stage in order, writing their output to log.
+// It fails on the first hook that fails with Abort set, the other failures are only logged.
+func (hooks Hooks) Run(stage Stage, log io.Writer) error {
+	for _, h := range hooks[stage] {
+		start := time.Now()
+		err := h.run(stage, log)
+		if err == nil {
+			log.Write([]byte(fmt.Sprintf("Hook %s %q finished in %s\n", stage, h.Name, time.Since(start))))
+			continue
+		}
+		log.Write([]byte(fmt.Sprintf("Hook %s %q failed after %s: %s\n", stage, h.Name, time.Since(start), err.Error())))
+		if h.Abort {
+			return errors.Wrapf(ErrHookFailed, "%s %q: %s", stage, h.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (h Hook) run(stage Stage, log io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Env = append(os.Environ(), h.Env...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// do not wait forever for output of descendants that outlive a killed hook
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		log.Write([]byte(fmt.Sprintf("[%s %s] %s\n", stage, h.Name, scanner.Text())))
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.Wrapf(ctx.Err(), "timeout %s exceeded", h.Timeout)
+	}
+
+	return err
+}
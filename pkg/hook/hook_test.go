@@ -0,0 +1,68 @@
+//go:build !windows
+
+package hook
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestNew(t *testing.T) {
+	hooks, err := New(config.Hooks{
+		PreStart: []config.Hook{{Command: []string{"migrate", "up"}, Env: map[string]string{"B": "2", "A": "1"}}},
+		PreStop:  []config.Hook{{Name: "deregister", Command: []string{"lb"}, OnFailure: "continue", TimeoutMs: 500}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, Hook{Name: "migrate up", Command: []string{"migrate", "up"}, Timeout: defaultTimeout, Env: []string{"A=1", "B=2"}, Abort: true}, hooks[StagePreStart][0])
+	require.Equal(t, Hook{Name: "deregister", Command: []string{"lb"}, Timeout: 500 * time.Millisecond}, hooks[StagePreStop][0])
+	require.Empty(t, hooks[StagePostStart])
+
+	_, err = New(config.Hooks{PostStop: []config.Hook{{}}})
+	require.ErrorIs(t, err, ErrMissingCommand)
+	_, err = New(config.Hooks{PostStop: []config.Hook{{Command: []string{"rm"}, OnFailure: "retry"}}})
+	require.ErrorIs(t, err, ErrInvalidOnFailure)
+}
+
+func TestRunCapturesOutputAndEnv(t *testing.T) {
+	hooks := Hooks{StagePreStart: {
+		{Name: "env", Command: []string{"sh", "-c", "echo value=$HOOK_VALUE; echo oops >&2"}, Timeout: time.Second, Env: []string{"HOOK_VALUE=42"}, Abort: true},
+	}}
+	var log bytes.Buffer
+
+	require.NoError(t, hooks.Run(StagePreStart, &log))
+	require.Contains(t, log.String(), "[pre-start env] value=42\n")
+	require.Contains(t, log.String(), "[pre-start env] oops\n")
+	require.Contains(t, log.String(), `Hook pre-start "env" finished in`)
+}
+
+func TestRunFailurePolicy(t *testing.T) {
+	hooks := Hooks{StagePostStop: {
+		{Name: "optional", Command: []string{"false"}, Timeout: time.Second},
+		{Name: "required", Command: []string{"sh", "-c", "exit 3"}, Timeout: time.Second, Abort: true},
+		{Name: "skipped", Command: []string{"true"}, Timeout: time.Second},
+	}}
+	var log bytes.Buffer
+
+	err := hooks.Run(StagePostStop, &log)
+	require.ErrorIs(t, err, ErrHookFailed)
+	require.Contains(t, err.Error(), `post-stop "required": exit status 3`)
+	require.Contains(t, log.String(), `Hook post-stop "optional" failed`)
+	require.NotContains(t, log.String(), "skipped")
+}
+
+func TestRunTimeout(t *testing.T) {
+	hooks := Hooks{StagePostStart: {
+		{Name: "slow", Command: []string{"sleep", "10"}, Timeout: 100 * time.Millisecond, Abort: true},
+	}}
+
+	start := time.Now()
+	err := hooks.Run(StagePostStart, &bytes.Buffer{})
+	require.ErrorIs(t, err, ErrHookFailed)
+	require.Contains(t, err.Error(), "timeout 100ms exceeded")
+	require.Less(t, time.Since(start), 5*time.Second)
+}
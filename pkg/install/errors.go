@@ -0,0 +1,13 @@
+package install
+
+import "github.com/pkg/errors"
+
+var (
+	ErrInvalidStartType   = errors.New("invalid start type")
+	ErrInvalidAccount     = errors.New("invalid account")
+	ErrPasswordNotAllowed = errors.New("password is not allowed for built-in accounts")
+	ErrInvalidDependency  = errors.New("invalid dependency")
+	ErrNameTooLong        = errors.New("name is longer than 256 characters")
+	ErrInvalidRecovery    = errors.New("invalid recovery action")
+	ErrRecoveryCommand    = errors.New("recovery command is required by the run-command action")
+)
@@ -0,0 +1,173 @@
+package install
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+// StartType is how the service is started, the values match the start types of the Windows service control manager
+type StartType uint32
+
+const (
+	StartAutomatic StartType = 2
+	StartManual    StartType = 3
+	StartDisabled  StartType = 4
+)
+
+// Names of the built-in accounts as passed to the service manager, LocalSystem is the empty account
+const (
+	LocalService   = `NT AUTHORITY\LocalService`
+	NetworkService = `NT AUTHORITY\NetworkService`
+)
+
+// maxNameLength is the longest service and display name accepted by the Windows service control manager
+const maxNameLength = 256
+
+// accountChars are the characters not allowed in account names
+const accountChars = `"/[]:;|=,+*?<>`
+
+// Options is the parsed form of the install settings of the service config with defaults applied,
+// independent of the platform service manager
+type Options struct {
+	DisplayName  string
+	StartType    StartType
+	DelayedStart bool
+	// Account is the account name passed to the service manager, empty for LocalSystem
+	Account  string
+	Password string
+	// Dependencies are the services, or groups prefixed with "+", started before the service
+	Dependencies []string
+	Recovery     Recovery
+}
+
+// NewOptions parses the install settings of the service config, the error is the *config.FieldError
+// of the first invalid setting
+func NewOptions(cfg config.WindowsServiceConfig) (Options, error) {
+	o, errs := parseOptions(cfg)
+	if len(errs) > 0 {
+		return o, errs[0]
+	}
+
+	return o, nil
+}
+
+// Check parses the install settings of the service config and returns every problem found
+func Check(cfg config.WindowsServiceConfig) []*config.FieldError {
+	_, errs := parseOptions(cfg)
+	return errs
+}
+
+func parseOptions(cfg config.WindowsServiceConfig) (Options, []*config.FieldError) {
+	o := Options{
+		DisplayName:  cfg.DisplayName,
+		Password:     cfg.Password,
+		Dependencies: cfg.Dependencies,
+	}
+	var errs []*config.FieldError
+	add := func(field string, err error) {
+		errs = append(errs, &config.FieldError{Field: field, Err: err})
+	}
+
+	if o.DisplayName == "" {
+		o.DisplayName = cfg.Name
+	}
+	if len(cfg.Name) > maxNameLength {
+		add("name", ErrNameTooLong)
+	}
+	if len(cfg.DisplayName) > maxNameLength {
+		add("displayName", ErrNameTooLong)
+	}
+
+	switch cfg.StartType {
+	case "", "manual":
+		o.StartType = StartManual
+	case "automatic":
+		o.StartType = StartAutomatic
+	case "delayed-automatic":
+		o.StartType, o.DelayedStart = StartAutomatic, true
+	case "disabled":
+		o.StartType = StartDisabled
+	default:
+		add("startType", errors.Wrapf(ErrInvalidStartType, "%q", cfg.StartType))
+	}
+
+	account, builtIn, err := parseAccount(cfg.Account)
+	if err != nil {
+		add("account", err)
+	}
+	if builtIn && o.Password != "" {
+		add("password", errors.Wrapf(ErrPasswordNotAllowed, "%q", cfg.Account))
+	}
+	o.Account = account
+
+	for i, dep := range o.Dependencies {
+		field := fmt.Sprintf("dependencies[%d]", i)
+		switch {
+		case strings.TrimPrefix(dep, "+") == "":
+			add(field, errors.Wrapf(ErrInvalidDependency, "%q is empty", dep))
+		case strings.EqualFold(dep, cfg.Name):
+			add(field, errors.Wrapf(ErrInvalidDependency, "%q is the service itself", dep))
+		case slices.IndexFunc(o.Dependencies[:i], func(d string) bool { return strings.EqualFold(d, dep) }) >= 0:
+			add(field, errors.Wrapf(ErrInvalidDependency, "%q is listed twice", dep))
+		}
+	}
+
+	var recoveryErrs []*config.FieldError
+	o.Recovery, recoveryErrs = parseRecovery(cfg.Recovery)
+	errs = append(errs, recoveryErrs...)
+
+	return o, errs
+}
+
+// parseAccount returns the account name passed to the service manager,
+// builtIn is set for LocalSystem, LocalService and NetworkService which have no password
+func parseAccount(account string) (name string, builtIn bool, err error) {
+	switch strings.TrimPrefix(strings.ToLower(account), `nt authority\`) {
+	case "", "localsystem", "system":
+		return "", true, nil
+	case "localservice":
+		return LocalService, true, nil
+	case "networkservice":
+		return NetworkService, true, nil
+	}
+
+	if strings.ContainsAny(account, accountChars) {
+		return "", false, errors.Wrapf(ErrInvalidAccount, "%q contains one of %s", account, accountChars)
+	}
+	domain, user, hasDomain := strings.Cut(account, `\`)
+	if !hasDomain {
+		user, domain, hasDomain = strings.Cut(account, "@")
+		if hasDomain {
+			// user principal names are passed as they are
+			if user == "" || domain == "" {
+				return "", false, errors.Wrapf(ErrInvalidAccount, "%q", account)
+			}
+			return account, false, nil
+		}
+		// a local account
+		return `.\` + account, false, nil
+	}
+	if domain == "" || user == "" || strings.Contains(user, `\`) {
+		return "", false, errors.Wrapf(ErrInvalidAccount, "%q", account)
+	}
+
+	return account, false, nil
+}
+
+// User returns the user name of a named account without its domain, empty for built-in accounts
+func (o Options) User() string {
+	if o.Account == "" || o.Account == LocalService || o.Account == NetworkService {
+		return ""
+	}
+	if _, user, ok := strings.Cut(o.Account, `\`); ok {
+		return user
+	}
+	user, _, _ := strings.Cut(o.Account, "@")
+
+	return user
+}
@@ -0,0 +1,123 @@
+package install
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestNewOptionsDefaults(t *testing.T) {
+	o, err := NewOptions(config.WindowsServiceConfig{Name: "api"})
+	require.NoError(t, err)
+	require.Equal(t, Options{DisplayName: "api", StartType: StartManual}, o)
+}
+
+func TestNewOptions(t *testing.T) {
+	o, err := NewOptions(config.WindowsServiceConfig{
+		Name:         "api",
+		DisplayName:  "Example API",
+		StartType:    "delayed-automatic",
+		Account:      `CORP\svc-api`,
+		Password:     "secret",
+		Dependencies: []string{"Tcpip", "+NetworkProvider"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, Options{
+		DisplayName:  "Example API",
+		StartType:    StartAutomatic,
+		DelayedStart: true,
+		Account:      `CORP\svc-api`,
+		Password:     "secret",
+		Dependencies: []string{"Tcpip", "+NetworkProvider"},
+	}, o)
+	require.Equal(t, "svc-api", o.User())
+}
+
+func TestNewOptionsStartTypes(t *testing.T) {
+	for startType, want := range map[string]StartType{
+		"":                  StartManual,
+		"manual":            StartManual,
+		"automatic":         StartAutomatic,
+		"delayed-automatic": StartAutomatic,
+		"disabled":          StartDisabled,
+	} {
+		o, err := NewOptions(config.WindowsServiceConfig{Name: "api", StartType: startType})
+		require.NoError(t, err)
+		require.Equal(t, want, o.StartType, startType)
+		require.Equal(t, startType == "delayed-automatic", o.DelayedStart, startType)
+	}
+}
+
+func TestNewOptionsAccounts(t *testing.T) {
+	for account, want := range map[string]string{
+		"":                            "",
+		"LocalSystem":                 "",
+		"localservice":                LocalService,
+		`NT AUTHORITY\NetworkService`: NetworkService,
+		"svc-api":                     `.\svc-api`,
+		`.\svc-api`:                   `.\svc-api`,
+		"svc-api@corp.example.com":    "svc-api@corp.example.com",
+		`CORP\gmsa-api$`:              `CORP\gmsa-api$`,
+	} {
+		o, err := NewOptions(config.WindowsServiceConfig{Name: "api", Account: account})
+		require.NoError(t, err, account)
+		require.Equal(t, want, o.Account, account)
+	}
+	o, _ := NewOptions(config.WindowsServiceConfig{Name: "api", Account: "NetworkService"})
+	require.Empty(t, o.User())
+	o, _ = NewOptions(config.WindowsServiceConfig{Name: "api", Account: "svc-api@corp.example.com"})
+	require.Equal(t, "svc-api", o.User())
+}
+
+func TestNewOptionsInvalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg config.WindowsServiceConfig
+		err error
+	}{
+		"start type":        {config.WindowsServiceConfig{StartType: "auto"}, ErrInvalidStartType},
+		"account chars":     {config.WindowsServiceConfig{Account: "svc:api"}, ErrInvalidAccount},
+		"account domain":    {config.WindowsServiceConfig{Account: `\svc-api`}, ErrInvalidAccount},
+		"account user":      {config.WindowsServiceConfig{Account: `CORP\`}, ErrInvalidAccount},
+		"account upn":       {config.WindowsServiceConfig{Account: "@corp"}, ErrInvalidAccount},
+		"built-in password": {config.WindowsServiceConfig{Account: "LocalService", Password: "secret"}, ErrPasswordNotAllowed},
+		"system password":   {config.WindowsServiceConfig{Password: "secret"}, ErrPasswordNotAllowed},
+		"empty dependency":  {config.WindowsServiceConfig{Dependencies: []string{"+"}}, ErrInvalidDependency},
+		"self dependency":   {config.WindowsServiceConfig{Dependencies: []string{"API"}}, ErrInvalidDependency},
+		"twice":             {config.WindowsServiceConfig{Dependencies: []string{"Tcpip", "tcpip"}}, ErrInvalidDependency},
+		"display name":      {config.WindowsServiceConfig{DisplayName: strings.Repeat("a", 257)}, ErrNameTooLong},
+	} {
+		tc.cfg.Name = "api"
+		_, err := NewOptions(tc.cfg)
+		require.ErrorIs(t, err, tc.err, name)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	require.Empty(t, Check(config.WindowsServiceConfig{Name: "api", StartType: "automatic"}))
+
+	errs := Check(config.WindowsServiceConfig{
+		Name:         "api",
+		DisplayName:  strings.Repeat("a", 300),
+		StartType:    "later",
+		Account:      `CORP\`,
+		Dependencies: []string{"", "api"},
+		Recovery:     config.Recovery{ResetPeriodMs: -1},
+	})
+	fields := make(map[string]error, len(errs))
+	for _, err := range errs {
+		fields[err.Field] = err
+	}
+	require.Len(t, fields, 6)
+	require.ErrorIs(t, fields["displayName"], ErrNameTooLong)
+	require.ErrorIs(t, fields["startType"], ErrInvalidStartType)
+	require.ErrorIs(t, fields["account"], ErrInvalidAccount)
+	require.ErrorIs(t, fields["dependencies[0]"], ErrInvalidDependency)
+	require.ErrorIs(t, fields["dependencies[1]"], ErrInvalidDependency)
+	require.ErrorIs(t, fields["recovery.resetPeriodMs"], ErrInvalidRecovery)
+
+	_, err := NewOptions(config.WindowsServiceConfig{Name: "api", StartType: "later"})
+	require.EqualError(t, err, `startType "later": invalid start type`)
+}
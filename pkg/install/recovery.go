@@ -0,0 +1,131 @@
+package install
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+// RecoveryActionType is what the service manager does when the service fails,
+// the values match the failure action types of the Windows service control manager
+type RecoveryActionType int
+
+const (
+	RecoveryNone       RecoveryActionType = 0
+	RecoveryRestart    RecoveryActionType = 1
+	RecoveryReboot     RecoveryActionType = 2
+	RecoveryRunCommand RecoveryActionType = 3
+)
+
+// defaultResetPeriod is the reset period of the failure count when actions are set without one
+const defaultResetPeriod = 24 * time.Hour
+
+func (t RecoveryActionType) String() string {
+	switch t {
+	case RecoveryNone:
+		return "none"
+	case RecoveryRestart:
+		return "restart"
+	case RecoveryReboot:
+		return "reboot"
+	case RecoveryRunCommand:
+		return "run-command"
+	default:
+		return fmt.Sprintf("unknown action %d", int(t))
+	}
+}
+
+// RecoveryAction is a failure action taken Delay after the service failed
+type RecoveryAction struct {
+	Type  RecoveryActionType `json:"type"`
+	Delay time.Duration      `json:"delay"`
+}
+
+func (a RecoveryAction) String() string {
+	return fmt.Sprintf("%s after %s", a.Type, a.Delay)
+}
+
+// Recovery is the parsed form of the recovery settings of the service config
+type Recovery struct {
+	Actions []RecoveryAction `json:"actions,omitempty"`
+	// ResetPeriod is a whole number of seconds, zero when there are no actions
+	ResetPeriod        time.Duration `json:"resetPeriod,omitempty"`
+	Command            string        `json:"command,omitempty"`
+	RebootMessage      string        `json:"rebootMessage,omitempty"`
+	OnNonCrashFailures bool          `json:"onNonCrashFailures,omitempty"`
+}
+
+// NewRecovery parses the recovery settings of the service config, the error is the *config.FieldError
+// of the first invalid setting
+func NewRecovery(cfg config.Recovery) (Recovery, error) {
+	r, errs := parseRecovery(cfg)
+	if len(errs) > 0 {
+		return r, errs[0]
+	}
+
+	return r, nil
+}
+
+func parseRecovery(cfg config.Recovery) (Recovery, []*config.FieldError) {
+	r := Recovery{
+		Command:            cfg.Command,
+		RebootMessage:      cfg.RebootMessage,
+		OnNonCrashFailures: cfg.OnNonCrashFailures,
+	}
+	var errs []*config.FieldError
+	add := func(field string, err error) {
+		errs = append(errs, &config.FieldError{Field: field, Err: err})
+	}
+
+	for i, a := range cfg.Actions {
+		field := fmt.Sprintf("recovery.actions[%d]", i)
+		action := RecoveryAction{Delay: time.Duration(a.DelayMs) * time.Millisecond}
+		switch a.Type {
+		case "none":
+			action.Type = RecoveryNone
+		case "restart":
+			action.Type = RecoveryRestart
+		case "reboot":
+			action.Type = RecoveryReboot
+		case "run-command":
+			action.Type = RecoveryRunCommand
+		default:
+			add(field+".type", errors.Wrapf(ErrInvalidRecovery, "%q", a.Type))
+		}
+		if a.DelayMs < 0 {
+			add(field+".delayMs", errors.Wrapf(ErrInvalidRecovery, "negative %d", a.DelayMs))
+		}
+		r.Actions = append(r.Actions, action)
+	}
+
+	runsCommand := slices.ContainsFunc(r.Actions, func(a RecoveryAction) bool { return a.Type == RecoveryRunCommand })
+	if runsCommand && strings.TrimSpace(cfg.Command) == "" {
+		add("recovery.command", ErrRecoveryCommand)
+	}
+	if cfg.ResetPeriodMs < 0 {
+		add("recovery.resetPeriodMs", errors.Wrapf(ErrInvalidRecovery, "negative %d", cfg.ResetPeriodMs))
+	}
+	if len(r.Actions) > 0 {
+		r.ResetPeriod = (time.Duration(cfg.ResetPeriodMs)*time.Millisecond + time.Second - 1).Truncate(time.Second)
+		if r.ResetPeriod == 0 {
+			r.ResetPeriod = defaultResetPeriod
+		}
+	}
+
+	return r, errs
+}
+
+// actionsString returns the actions as a comma separated list
+func actionsString(actions []RecoveryAction) string {
+	list := make([]string, len(actions))
+	for i, a := range actions {
+		list[i] = a.String()
+	}
+
+	return "[" + strings.Join(list, ", ") + "]"
+}
@@ -0,0 +1,80 @@
+package install
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestNewRecovery(t *testing.T) {
+	r, err := NewRecovery(config.Recovery{})
+	require.NoError(t, err)
+	require.Equal(t, Recovery{}, r)
+
+	r, err = NewRecovery(config.Recovery{
+		Actions: []config.RecoveryAction{
+			{Type: "restart", DelayMs: 5000},
+			{Type: "run-command", DelayMs: 1500},
+			{Type: "reboot", DelayMs: 60000},
+		},
+		ResetPeriodMs:      3600500,
+		Command:            `C:\scripts\notify.cmd`,
+		RebootMessage:      "Rebooting after the service failed",
+		OnNonCrashFailures: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, Recovery{
+		Actions: []RecoveryAction{
+			{Type: RecoveryRestart, Delay: 5 * time.Second},
+			{Type: RecoveryRunCommand, Delay: 1500 * time.Millisecond},
+			{Type: RecoveryReboot, Delay: time.Minute},
+		},
+		ResetPeriod:        time.Hour + time.Second,
+		Command:            `C:\scripts\notify.cmd`,
+		RebootMessage:      "Rebooting after the service failed",
+		OnNonCrashFailures: true,
+	}, r)
+
+	r, err = NewRecovery(config.Recovery{Actions: []config.RecoveryAction{{Type: "restart"}, {Type: "none"}}})
+	require.NoError(t, err)
+	require.Equal(t, []RecoveryAction{{Type: RecoveryRestart}, {Type: RecoveryNone}}, r.Actions)
+	require.Equal(t, 24*time.Hour, r.ResetPeriod)
+}
+
+func TestNewRecoveryErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg config.Recovery
+		err error
+	}{
+		"unknown type":        {config.Recovery{Actions: []config.RecoveryAction{{Type: "restart-service"}}}, ErrInvalidRecovery},
+		"negative delay":      {config.Recovery{Actions: []config.RecoveryAction{{Type: "restart", DelayMs: -1}}}, ErrInvalidRecovery},
+		"negative reset":      {config.Recovery{ResetPeriodMs: -1}, ErrInvalidRecovery},
+		"command is required": {config.Recovery{Actions: []config.RecoveryAction{{Type: "run-command"}}}, ErrRecoveryCommand},
+	} {
+		_, err := NewRecovery(tc.cfg)
+		require.ErrorIs(t, err, tc.err, name)
+	}
+
+	_, err := NewOptions(config.WindowsServiceConfig{Name: "api", Recovery: config.Recovery{Actions: []config.RecoveryAction{{Type: "reset"}}}})
+	require.ErrorIs(t, err, ErrInvalidRecovery)
+}
+
+func TestDiffRecovery(t *testing.T) {
+	installed := Settings{StartType: StartManual}
+	next := installed
+	next.Recovery = Recovery{
+		Actions:     []RecoveryAction{{Type: RecoveryRestart, Delay: 5 * time.Second}},
+		ResetPeriod: 24 * time.Hour,
+	}
+	require.Equal(t, []Change{
+		{Field: "recovery.actions", From: "[]", To: "[restart after 5s]"},
+		{Field: "recovery.resetPeriodMs", From: "0s", To: "24h0m0s"},
+	}, Diff(installed, next))
+
+	installed.Recovery = next.Recovery
+	next.Recovery.OnNonCrashFailures = true
+	require.Equal(t, []Change{{Field: "recovery.onNonCrashFailures", From: "false", To: "true"}}, Diff(installed, next))
+}
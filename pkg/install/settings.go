@@ -0,0 +1,155 @@
+package install
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+// Settings are the settings the service manager keeps for an installed service,
+// the password of the account can not be read back and is left out
+type Settings struct {
+	DisplayName  string    `json:"displayName"`
+	Description  string    `json:"description"`
+	ExecPath     string    `json:"execPath"`
+	Args         []string  `json:"args,omitempty"`
+	StartType    StartType `json:"startType"`
+	DelayedStart bool      `json:"delayedStart,omitempty"`
+	// Account is the account name passed to the service manager, empty for LocalSystem
+	Account      string   `json:"account,omitempty"`
+	Dependencies []string `json:"dependencies,omitempty"`
+	Recovery     Recovery `json:"recovery"`
+}
+
+// NewSettings returns the settings of the service described by cfg running cfg.ParentExecPath with args
+func NewSettings(cfg config.WindowsServiceConfig, args ...string) (Settings, error) {
+	o, err := NewOptions(cfg)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	return o.Settings(cfg, args...), nil
+}
+
+// Settings returns the settings of the service described by cfg with the options o parsed from it
+func (o Options) Settings(cfg config.WindowsServiceConfig, args ...string) Settings {
+	return Settings{
+		DisplayName:  o.DisplayName,
+		Description:  cfg.Description,
+		ExecPath:     cfg.ParentExecPath,
+		Args:         args,
+		StartType:    o.StartType,
+		DelayedStart: o.DelayedStart,
+		Account:      o.Account,
+		Dependencies: o.Dependencies,
+		Recovery:     o.Recovery,
+	}
+}
+
+func (t StartType) String() string {
+	switch t {
+	case StartAutomatic:
+		return "automatic"
+	case StartManual:
+		return "manual"
+	case StartDisabled:
+		return "disabled"
+	default:
+		return fmt.Sprintf("unknown start type %d", uint32(t))
+	}
+}
+
+// startType returns the start type of s as it is written in the config
+func (s Settings) startType() string {
+	if s.StartType == StartAutomatic && s.DelayedStart {
+		return "delayed-automatic"
+	}
+
+	return s.StartType.String()
+}
+
+// Change is a setting of the installed service differing from the config, Field is its JSON path in the config
+type Change struct {
+	Field string
+	From  string
+	To    string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Field, c.From, c.To)
+}
+
+// Diff returns the settings of next differing from the installed ones, field by field.
+// Account and dependency names are compared ignoring case as the service manager does.
+func Diff(installed, next Settings) []Change {
+	var changes []Change
+	add := func(field string, from, to any) {
+		changes = append(changes, Change{Field: field, From: fmt.Sprintf("%q", from), To: fmt.Sprintf("%q", to)})
+	}
+
+	if installed.DisplayName != next.DisplayName {
+		add("displayName", installed.DisplayName, next.DisplayName)
+	}
+	if installed.Description != next.Description {
+		add("description", installed.Description, next.Description)
+	}
+	if installed.ExecPath != next.ExecPath {
+		add("parentExecPath", installed.ExecPath, next.ExecPath)
+	}
+	if !slices.Equal(installed.Args, next.Args) {
+		add("args", orEmpty(installed.Args), orEmpty(next.Args))
+	}
+	if installed.startType() != next.startType() {
+		changes = append(changes, Change{Field: "startType", From: installed.startType(), To: next.startType()})
+	}
+	if !strings.EqualFold(installed.Account, next.Account) {
+		add("account", accountName(installed.Account), accountName(next.Account))
+	}
+	if !slices.EqualFunc(installed.Dependencies, next.Dependencies, strings.EqualFold) {
+		add("dependencies", orEmpty(installed.Dependencies), orEmpty(next.Dependencies))
+	}
+	changes = append(changes, diffRecovery(installed.Recovery, next.Recovery)...)
+
+	return changes
+}
+
+// diffRecovery returns the recovery settings of next differing from the installed ones
+func diffRecovery(installed, next Recovery) []Change {
+	var changes []Change
+	if from, to := actionsString(installed.Actions), actionsString(next.Actions); from != to {
+		changes = append(changes, Change{Field: "recovery.actions", From: from, To: to})
+	}
+	if installed.ResetPeriod != next.ResetPeriod {
+		changes = append(changes, Change{Field: "recovery.resetPeriodMs", From: installed.ResetPeriod.String(), To: next.ResetPeriod.String()})
+	}
+	if installed.Command != next.Command {
+		changes = append(changes, Change{Field: "recovery.command", From: fmt.Sprintf("%q", installed.Command), To: fmt.Sprintf("%q", next.Command)})
+	}
+	if installed.RebootMessage != next.RebootMessage {
+		changes = append(changes, Change{Field: "recovery.rebootMessage", From: fmt.Sprintf("%q", installed.RebootMessage), To: fmt.Sprintf("%q", next.RebootMessage)})
+	}
+	if installed.OnNonCrashFailures != next.OnNonCrashFailures {
+		changes = append(changes, Change{Field: "recovery.onNonCrashFailures", From: fmt.Sprint(installed.OnNonCrashFailures), To: fmt.Sprint(next.OnNonCrashFailures)})
+	}
+
+	return changes
+}
+
+// accountName returns the name of account as it is written in the config
+func accountName(account string) string {
+	if account == "" {
+		return "LocalSystem"
+	}
+
+	return account
+}
+
+func orEmpty(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+
+	return list
+}
@@ -0,0 +1,43 @@
+package install
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestDiff(t *testing.T) {
+	installed, err := NewSettings(config.WindowsServiceConfig{
+		Name:           "api",
+		Description:    "Example API",
+		ParentExecPath: `C:\svc\service.exe`,
+		Account:        `CORP\svc-api`,
+		Dependencies:   []string{"Tcpip"},
+	})
+	require.NoError(t, err)
+	require.Empty(t, Diff(installed, installed))
+
+	next := installed
+	next.Account = `corp\SVC-API`
+	next.Dependencies = []string{"TCPIP"}
+	require.Empty(t, Diff(installed, next))
+
+	next, err = NewSettings(config.WindowsServiceConfig{
+		Name:           "api",
+		DisplayName:    "API",
+		Description:    "Example API",
+		ParentExecPath: `C:\svc\service.exe`,
+		StartType:      "delayed-automatic",
+	}, "-config", "api.json")
+	require.NoError(t, err)
+	require.Equal(t, []Change{
+		{Field: "displayName", From: `"api"`, To: `"API"`},
+		{Field: "args", From: `[]`, To: `["-config" "api.json"]`},
+		{Field: "startType", From: "manual", To: "delayed-automatic"},
+		{Field: "account", From: `"CORP\\svc-api"`, To: `"LocalSystem"`},
+		{Field: "dependencies", From: `["Tcpip"]`, To: `[]`},
+	}, Diff(installed, next))
+	require.Equal(t, "startType: manual -> delayed-automatic", Diff(installed, next)[2].String())
+}
@@ -0,0 +1,96 @@
+package restart
+
+import (
+	"time"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+const defaultCrashLoopWindow = 5 * time.Minute
+
+// CrashLoopPolicy is the parsed form of config.CrashLoop with defaults applied
+type CrashLoopPolicy struct {
+	MaxRestarts  int
+	Window       time.Duration
+	StableUptime time.Duration
+}
+
+// NewCrashLoopPolicy parses the crash loop section of the service config
+func NewCrashLoopPolicy(cfg config.CrashLoop) (CrashLoopPolicy, error) {
+	p := CrashLoopPolicy{
+		MaxRestarts:  cfg.MaxRestarts,
+		Window:       time.Duration(cfg.WindowMs) * time.Millisecond,
+		StableUptime: time.Duration(cfg.StableUptimeMs) * time.Millisecond,
+	}
+
+	if p.MaxRestarts < 0 {
+		return p, ErrInvalidCrashLoopRestarts
+	}
+	if p.Window < 0 || p.StableUptime < 0 {
+		return p, ErrInvalidDelay
+	}
+	if p.Window == 0 {
+		p.Window = defaultCrashLoopWindow
+	}
+	if p.StableUptime == 0 {
+		p.StableUptime = p.Window
+	}
+
+	return p, nil
+}
+
+// Breaker detects a child process that keeps restarting within a sliding window
+type Breaker struct {
+	policy    CrashLoopPolicy
+	clock     clock.Clock
+	restarts  []time.Time
+	startedAt time.Time
+}
+
+func NewBreaker(policy CrashLoopPolicy, clk clock.Clock) *Breaker {
+	return &Breaker{
+		policy: policy,
+		clock:  clk,
+	}
+}
+
+// Started records the moment the child process came up
+func (b *Breaker) Started() {
+	b.startedAt = b.clock.Now()
+}
+
+// Record registers a restart and reports whether the restart limit within the window is exceeded.
+// Restarts are forgotten once the child stays up for Policy.StableUptime.
+func (b *Breaker) Record() (tripped bool) {
+	now := b.clock.Now()
+	if !b.startedAt.IsZero() && now.Sub(b.startedAt) >= b.policy.StableUptime {
+		b.restarts = b.restarts[:0]
+	}
+	b.startedAt = time.Time{}
+
+	if b.policy.MaxRestarts == 0 {
+		return false
+	}
+
+	windowStart := now.Add(-b.policy.Window)
+	recent := b.restarts[:0]
+	for _, t := range b.restarts {
+		if t.After(windowStart) {
+			recent = append(recent, t)
+		}
+	}
+	b.restarts = append(recent, now)
+
+	return len(b.restarts) > b.policy.MaxRestarts
+}
+
+// Restarts returns the number of restarts within the current window
+func (b *Breaker) Restarts() int {
+	return len(b.restarts)
+}
+
+// SetPolicy replaces the policy keeping the restarts recorded so far
+func (b *Breaker) SetPolicy(policy CrashLoopPolicy) {
+	b.policy = policy
+}
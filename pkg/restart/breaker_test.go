@@ -0,0 +1,63 @@
+package restart
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestNewCrashLoopPolicyDefaults(t *testing.T) {
+	p, err := NewCrashLoopPolicy(config.CrashLoop{MaxRestarts: 3})
+	require.NoError(t, err)
+	require.Equal(t, defaultCrashLoopWindow, p.Window)
+	require.Equal(t, defaultCrashLoopWindow, p.StableUptime)
+
+	_, err = NewCrashLoopPolicy(config.CrashLoop{MaxRestarts: -1})
+	require.ErrorIs(t, err, ErrInvalidCrashLoopRestarts)
+}
+
+func TestBreakerTripsWithinWindow(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	b := NewBreaker(CrashLoopPolicy{MaxRestarts: 3, Window: time.Minute, StableUptime: time.Minute}, clk)
+
+	for i := 0; i < 3; i++ {
+		require.False(t, b.Record())
+		clk.Advance(time.Second)
+	}
+	require.True(t, b.Record())
+	require.Equal(t, 4, b.Restarts())
+}
+
+func TestBreakerSlidingWindow(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	b := NewBreaker(CrashLoopPolicy{MaxRestarts: 2, Window: 10 * time.Second, StableUptime: time.Hour}, clk)
+
+	for i := 0; i < 10; i++ {
+		require.False(t, b.Record())
+		clk.Advance(6 * time.Second)
+	}
+	require.Equal(t, 2, b.Restarts())
+}
+
+func TestBreakerResetsAfterStableUptime(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	b := NewBreaker(CrashLoopPolicy{MaxRestarts: 2, Window: time.Hour, StableUptime: 30 * time.Second}, clk)
+
+	require.False(t, b.Record())
+	require.False(t, b.Record())
+	b.Started()
+	clk.Advance(30 * time.Second)
+	require.False(t, b.Record())
+	require.Equal(t, 1, b.Restarts())
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := NewBreaker(CrashLoopPolicy{Window: time.Minute, StableUptime: time.Minute}, clock.NewFake(time.Now()))
+	for i := 0; i < 100; i++ {
+		require.False(t, b.Record())
+	}
+}
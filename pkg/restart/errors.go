@@ -0,0 +1,14 @@
+package restart
+
+import "github.com/pkg/errors"
+
+var (
+	ErrInvalidMode       = errors.New("invalid restart mode")
+	ErrInvalidDelay      = errors.New("restart delays must not be negative")
+	ErrInvalidMultiplier = errors.New("restart multiplier must be at least 1")
+	ErrInvalidJitter     = errors.New("restart jitter must be in range [0, 1]")
+	ErrInvalidAttempts   = errors.New("restart max attempts must not be negative")
+	ErrInvalidCleanExit  = errors.New("invalid clean exit action")
+
+	ErrInvalidCrashLoopRestarts = errors.New("crash loop max restarts must not be negative")
+)
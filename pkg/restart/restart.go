@@ -0,0 +1,229 @@
+package restart
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+type Mode string
+
+const (
+	ModeAlways    Mode = "always"
+	ModeOnFailure Mode = "on-failure"
+	ModeNever     Mode = "never"
+)
+
+type CleanExitAction string
+
+const (
+	CleanExitStop           CleanExitAction = "stop"
+	CleanExitRestart        CleanExitAction = "restart"
+	CleanExitRestartDelayed CleanExitAction = "restart-delayed"
+)
+
+// Action is what the supervisor does after the child process exits
+type Action int
+
+const (
+	// ActionStop stops the service after a clean exit
+	ActionStop Action = iota
+	// ActionFail stops the service after a failure that must not be restarted
+	ActionFail
+	// ActionRestart restarts the child after a clean exit once Policy.CleanExitDelay passes
+	ActionRestart
+	// ActionBackoff restarts the child after a failure once the backoff delay passes
+	ActionBackoff
+)
+
+const (
+	defaultInitialDelay = time.Second
+	defaultMaxDelay     = time.Minute
+	defaultMultiplier   = 2.0
+	defaultCleanDelay   = 5 * time.Second
+)
+
+// Policy is the parsed form of config.RestartPolicy with defaults applied
+type Policy struct {
+	Mode               Mode
+	InitialDelay       time.Duration
+	MaxDelay           time.Duration
+	Multiplier         float64
+	Jitter             float64
+	MaxAttempts        int
+	CleanExit          CleanExitAction
+	CleanExitDelay     time.Duration
+	SuccessExitCodes   []int
+	NoRestartExitCodes []int
+}
+
+// NewPolicy parses the restart policy section of the service config
+func NewPolicy(cfg config.RestartPolicy) (Policy, error) {
+	p := Policy{
+		Mode:               Mode(cfg.Mode),
+		InitialDelay:       time.Duration(cfg.InitialDelayMs) * time.Millisecond,
+		MaxDelay:           time.Duration(cfg.MaxDelayMs) * time.Millisecond,
+		Multiplier:         cfg.Multiplier,
+		Jitter:             cfg.Jitter,
+		MaxAttempts:        cfg.MaxAttempts,
+		CleanExit:          CleanExitAction(cfg.OnCleanExit),
+		CleanExitDelay:     time.Duration(cfg.CleanExitDelayMs) * time.Millisecond,
+		SuccessExitCodes:   cfg.SuccessExitCodes,
+		NoRestartExitCodes: cfg.NoRestartExitCodes,
+	}
+
+	switch p.Mode {
+	case "":
+		p.Mode = ModeOnFailure
+	case ModeAlways, ModeOnFailure, ModeNever:
+	default:
+		return p, errors.Wrapf(ErrInvalidMode, "%q", cfg.Mode)
+	}
+	if p.InitialDelay < 0 || p.MaxDelay < 0 {
+		return p, ErrInvalidDelay
+	}
+	if p.InitialDelay == 0 {
+		p.InitialDelay = defaultInitialDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = max(defaultMaxDelay, p.InitialDelay)
+	}
+	if p.MaxDelay < p.InitialDelay {
+		p.MaxDelay = p.InitialDelay
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = defaultMultiplier
+	}
+	if p.Multiplier < 1 {
+		return p, ErrInvalidMultiplier
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return p, ErrInvalidJitter
+	}
+	if p.MaxAttempts < 0 {
+		return p, ErrInvalidAttempts
+	}
+
+	switch p.CleanExit {
+	case "":
+		p.CleanExit = CleanExitStop
+		if p.Mode == ModeAlways {
+			p.CleanExit = CleanExitRestart
+		}
+	case CleanExitStop, CleanExitRestart:
+	case CleanExitRestartDelayed:
+		if p.CleanExitDelay == 0 {
+			p.CleanExitDelay = defaultCleanDelay
+		}
+	default:
+		return p, errors.Wrapf(ErrInvalidCleanExit, "%q", cfg.OnCleanExit)
+	}
+	if p.CleanExitDelay < 0 {
+		return p, ErrInvalidDelay
+	}
+	if p.CleanExit == CleanExitRestart {
+		p.CleanExitDelay = p.InitialDelay
+	}
+
+	return p, nil
+}
+
+// Scheduler computes exponential backoff delays between restarts of the child process.
+// The backoff is reset once the child stays up for at least Policy.MaxDelay.
+type Scheduler struct {
+	policy    Policy
+	clock     clock.Clock
+	random    func() float64
+	attempts  int
+	startedAt time.Time
+}
+
+func NewScheduler(policy Policy, clk clock.Clock) *Scheduler {
+	return &Scheduler{
+		policy: policy,
+		clock:  clk,
+		random: rand.Float64,
+	}
+}
+
+// Decide returns what to do with a child that exited with exitErr.
+// Clean exits follow Policy.CleanExit, failures follow Policy.Mode unless
+// the exit code is listed in Policy.NoRestartExitCodes.
+func (s *Scheduler) Decide(exitErr error) Action {
+	code := ExitCode(exitErr)
+	if exitErr == nil || slices.Contains(s.policy.SuccessExitCodes, code) {
+		if s.policy.CleanExit == CleanExitStop || slices.Contains(s.policy.NoRestartExitCodes, code) {
+			return ActionStop
+		}
+		return ActionRestart
+	}
+
+	if s.policy.Mode == ModeNever || slices.Contains(s.policy.NoRestartExitCodes, code) {
+		return ActionFail
+	}
+
+	return ActionBackoff
+}
+
+// ExitCode returns the exit code carried by the error returned from waiting on the child,
+// 0 for a nil error and -1 if the error carries no exit code
+func ExitCode(exitErr error) int {
+	if exitErr == nil {
+		return 0
+	}
+	var coder interface{ ExitCode() int }
+	if errors.As(exitErr, &coder) {
+		return coder.ExitCode()
+	}
+
+	return -1
+}
+
+// Started records the moment the child process came up
+func (s *Scheduler) Started() {
+	s.startedAt = s.clock.Now()
+}
+
+// Next returns the delay before the next restart attempt,
+// ok is false once Policy.MaxAttempts consecutive attempts have been made
+func (s *Scheduler) Next() (delay time.Duration, ok bool) {
+	if !s.startedAt.IsZero() && s.clock.Now().Sub(s.startedAt) >= s.policy.MaxDelay {
+		s.attempts = 0
+	}
+	s.startedAt = time.Time{}
+
+	if s.policy.MaxAttempts > 0 && s.attempts >= s.policy.MaxAttempts {
+		return 0, false
+	}
+
+	d := float64(s.policy.InitialDelay) * math.Pow(s.policy.Multiplier, float64(s.attempts))
+	if d > float64(s.policy.MaxDelay) {
+		d = float64(s.policy.MaxDelay)
+	}
+	d -= d * s.policy.Jitter * s.random()
+	s.attempts++
+
+	return time.Duration(d), true
+}
+
+// Attempts returns the number of consecutive restart attempts made so far
+func (s *Scheduler) Attempts() int {
+	return s.attempts
+}
+
+// Reset forgets previous attempts so the next delay starts from Policy.InitialDelay
+func (s *Scheduler) Reset() {
+	s.attempts = 0
+	s.startedAt = time.Time{}
+}
+
+// SetPolicy replaces the policy keeping the attempts made so far
+func (s *Scheduler) SetPolicy(policy Policy) {
+	s.policy = policy
+}
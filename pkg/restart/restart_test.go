@@ -0,0 +1,180 @@
+package restart
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestNewPolicyDefaults(t *testing.T) {
+	p, err := NewPolicy(config.RestartPolicy{})
+	require.NoError(t, err)
+	require.Equal(t, ModeOnFailure, p.Mode)
+	require.Equal(t, defaultInitialDelay, p.InitialDelay)
+	require.Equal(t, defaultMaxDelay, p.MaxDelay)
+	require.Equal(t, defaultMultiplier, p.Multiplier)
+}
+
+func TestNewPolicyInvalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg config.RestartPolicy
+		err error
+	}{
+		"mode":       {config.RestartPolicy{Mode: "sometimes"}, ErrInvalidMode},
+		"delay":      {config.RestartPolicy{InitialDelayMs: -1}, ErrInvalidDelay},
+		"multiplier": {config.RestartPolicy{Multiplier: 0.5}, ErrInvalidMultiplier},
+		"jitter":     {config.RestartPolicy{Jitter: 1.5}, ErrInvalidJitter},
+		"attempts":   {config.RestartPolicy{MaxAttempts: -1}, ErrInvalidAttempts},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewPolicy(tc.cfg)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+
+func (e exitError) ExitCode() int { return int(e) }
+
+func TestDecide(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg     config.RestartPolicy
+		exitErr error
+		want    Action
+	}{
+		"on-failure clean":        {config.RestartPolicy{}, nil, ActionStop},
+		"on-failure failed":       {config.RestartPolicy{}, exitError(1), ActionBackoff},
+		"always clean":            {config.RestartPolicy{Mode: "always"}, nil, ActionRestart},
+		"never failed":            {config.RestartPolicy{Mode: "never"}, exitError(1), ActionFail},
+		"never clean restart":     {config.RestartPolicy{Mode: "never", OnCleanExit: "restart"}, nil, ActionRestart},
+		"unknown error":           {config.RestartPolicy{}, errors.New("wait failed"), ActionBackoff},
+		"success code":            {config.RestartPolicy{SuccessExitCodes: []int{3}}, exitError(3), ActionStop},
+		"success code restart":    {config.RestartPolicy{OnCleanExit: "restart-delayed", SuccessExitCodes: []int{3}}, exitError(3), ActionRestart},
+		"no restart code":         {config.RestartPolicy{Mode: "always", NoRestartExitCodes: []int{4}}, exitError(4), ActionFail},
+		"no restart success code": {config.RestartPolicy{Mode: "always", NoRestartExitCodes: []int{0}}, nil, ActionStop},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewPolicy(tc.cfg)
+			require.NoError(t, err)
+			s := NewScheduler(p, clock.NewFake(time.Now()))
+			require.Equal(t, tc.want, s.Decide(tc.exitErr))
+		})
+	}
+}
+
+func TestCleanExitDelay(t *testing.T) {
+	p, err := NewPolicy(config.RestartPolicy{OnCleanExit: "restart-delayed"})
+	require.NoError(t, err)
+	require.Equal(t, defaultCleanDelay, p.CleanExitDelay)
+
+	p, err = NewPolicy(config.RestartPolicy{OnCleanExit: "restart", InitialDelayMs: 200})
+	require.NoError(t, err)
+	require.Equal(t, 200*time.Millisecond, p.CleanExitDelay)
+
+	_, err = NewPolicy(config.RestartPolicy{OnCleanExit: "ignore"})
+	require.ErrorIs(t, err, ErrInvalidCleanExit)
+}
+
+func TestExitCode(t *testing.T) {
+	require.Equal(t, 0, ExitCode(nil))
+	require.Equal(t, 7, ExitCode(errors.Wrap(exitError(7), "wait")))
+	require.Equal(t, -1, ExitCode(errors.New("wait failed")))
+}
+
+func TestSchedulerBackoff(t *testing.T) {
+	p, err := NewPolicy(config.RestartPolicy{
+		InitialDelayMs: 100,
+		MaxDelayMs:     1000,
+		Multiplier:     3,
+		MaxAttempts:    5,
+	})
+	require.NoError(t, err)
+	s := NewScheduler(p, clock.NewFake(time.Now()))
+
+	for _, want := range []time.Duration{100, 300, 900, 1000, 1000} {
+		delay, ok := s.Next()
+		require.True(t, ok)
+		require.Equal(t, want*time.Millisecond, delay)
+	}
+	_, ok := s.Next()
+	require.False(t, ok)
+	require.Equal(t, 5, s.Attempts())
+}
+
+func TestSchedulerSetPolicy(t *testing.T) {
+	p, err := NewPolicy(config.RestartPolicy{InitialDelayMs: 100, MaxAttempts: 2})
+	require.NoError(t, err)
+	s := NewScheduler(p, clock.NewFake(time.Now()))
+	s.Next()
+	s.Next()
+
+	p, err = NewPolicy(config.RestartPolicy{InitialDelayMs: 100, MaxAttempts: 3})
+	require.NoError(t, err)
+	s.SetPolicy(p)
+	delay, ok := s.Next()
+	require.True(t, ok)
+	require.Equal(t, 400*time.Millisecond, delay)
+	require.Equal(t, 3, s.Attempts())
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	p, err := NewPolicy(config.RestartPolicy{InitialDelayMs: 1000, Jitter: 0.5})
+	require.NoError(t, err)
+	s := NewScheduler(p, clock.NewFake(time.Now()))
+	s.random = func() float64 { return 1 }
+
+	delay, ok := s.Next()
+	require.True(t, ok)
+	require.Equal(t, 500*time.Millisecond, delay)
+}
+
+func TestSchedulerResetsAfterStableUptime(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	p, err := NewPolicy(config.RestartPolicy{InitialDelayMs: 100, MaxDelayMs: 400})
+	require.NoError(t, err)
+	s := NewScheduler(p, clk)
+
+	s.Next()
+	s.Started()
+	clk.Advance(100 * time.Millisecond)
+	delay, _ := s.Next()
+	require.Equal(t, 200*time.Millisecond, delay)
+
+	s.Started()
+	clk.Advance(400 * time.Millisecond)
+	delay, _ = s.Next()
+	require.Equal(t, 100*time.Millisecond, delay)
+	require.Equal(t, 1, s.Attempts())
+}
+
+func TestFakeClockFiresTimers(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	p, err := NewPolicy(config.RestartPolicy{InitialDelayMs: 250})
+	require.NoError(t, err)
+	s := NewScheduler(p, clk)
+
+	delay, _ := s.Next()
+	timer := clk.After(delay)
+	clk.Advance(200 * time.Millisecond)
+	select {
+	case <-timer:
+		t.Fatal("timer fired before the backoff delay")
+	default:
+	}
+	clk.Advance(50 * time.Millisecond)
+	select {
+	case <-timer:
+	default:
+		t.Fatal("timer did not fire after the backoff delay")
+	}
+	require.Zero(t, clk.Waiters())
+}
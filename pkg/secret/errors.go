@@ -0,0 +1,11 @@
+package secret
+
+import "github.com/pkg/errors"
+
+var (
+	ErrNoKey        = errors.New("no key to encrypt the secrets with")
+	ErrWrongKey     = errors.New("wrong key or corrupted secrets store")
+	ErrInvalidStore = errors.New("invalid secrets store")
+	ErrInvalidName  = errors.New("invalid secret name")
+	ErrSecretNotSet = errors.New("secret is not set")
+)
@@ -0,0 +1,26 @@
+package secret
+
+import (
+	"sort"
+	"strings"
+)
+
+// Mask replaces secret values in redacted output
+const Mask = "****"
+
+// Redact replaces every occurrence of the values in s with Mask, longer values first
+func Redact(s string, values []string) string {
+	sorted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			sorted = append(sorted, v)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	for _, v := range sorted {
+		s = strings.ReplaceAll(s, v, Mask)
+	}
+
+	return s
+}
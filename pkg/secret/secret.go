@@ -0,0 +1,225 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	storeVersion = 1
+	saltSize     = 16
+	keySize      = 32
+)
+
+// iterations of PBKDF2 used for new stores, lowered in tests
+var iterations = 600_000
+
+// namePattern restricts secret names to the characters allowed in ${secret:name} references
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// storeFile is the layout of the store on disk, the secrets are encrypted with AES-256-GCM
+// under a key derived from the key material with PBKDF2-HMAC-SHA256
+type storeFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Store is a set of named secrets kept in a file encrypted with AES-256-GCM
+type Store struct {
+	path       string
+	aead       cipher.AEAD
+	salt       []byte
+	iterations int
+	secrets    map[string]string
+}
+
+// Open decrypts the store at path with the key material, which is a passphrase or the content of a key file.
+// A missing file opens an empty store that is created on Save.
+func Open(path string, key []byte) (*Store, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, errors.Wrap(err, "can not generate salt")
+		}
+		aead, err := newAEAD(key, salt, iterations)
+		if err != nil {
+			return nil, err
+		}
+		return &Store{path: path, aead: aead, salt: salt, iterations: iterations, secrets: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not read secrets store %s", path)
+	}
+
+	var f storeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, errors.Wrapf(ErrInvalidStore, "%s: %s", path, err.Error())
+	}
+	if f.Version != storeVersion || f.Iterations <= 0 || len(f.Salt) == 0 {
+		return nil, errors.Wrapf(ErrInvalidStore, "%s: unsupported version %d", path, f.Version)
+	}
+	aead, err := newAEAD(key, f.Salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != aead.NonceSize() {
+		return nil, errors.Wrapf(ErrInvalidStore, "%s: invalid nonce", path)
+	}
+	plaintext, err := aead.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrapf(ErrWrongKey, "%s", path)
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, errors.Wrapf(ErrInvalidStore, "%s: %s", path, err.Error())
+	}
+
+	return &Store{path: path, aead: aead, salt: f.Salt, iterations: f.Iterations, secrets: secrets}, nil
+}
+
+// ReadKeyFile reads the key material from the file at path, trailing whitespace is ignored
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not read key file %s", path)
+	}
+
+	return []byte(strings.TrimRight(string(data), " \t\r\n")), nil
+}
+
+// Get returns the value of the secret name
+func (s *Store) Get(name string) (string, error) {
+	value, ok := s.secrets[name]
+	if !ok {
+		return "", errors.Wrapf(ErrSecretNotSet, "%q", name)
+	}
+
+	return value, nil
+}
+
+// Set sets the value of the secret name, the store must be saved to keep it
+func (s *Store) Set(name, value string) error {
+	if !namePattern.MatchString(name) {
+		return errors.Wrapf(ErrInvalidName, "%q", name)
+	}
+	s.secrets[name] = value
+
+	return nil
+}
+
+// Remove removes the secret name, the store must be saved to keep the change
+func (s *Store) Remove(name string) error {
+	if _, ok := s.secrets[name]; !ok {
+		return errors.Wrapf(ErrSecretNotSet, "%q", name)
+	}
+	delete(s.secrets, name)
+
+	return nil
+}
+
+// Names returns the sorted names of the secrets
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.secrets))
+	for name := range s.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Save encrypts the secrets with a fresh nonce and replaces the store file, which is readable by its owner only
+func (s *Store) Save() error {
+	plaintext, err := json.Marshal(s.secrets)
+	if err != nil {
+		return errors.Wrap(err, "can not encode secrets")
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "can not generate nonce")
+	}
+	data, err := json.MarshalIndent(storeFile{
+		Version:    storeVersion,
+		Iterations: s.iterations,
+		Salt:       s.salt,
+		Nonce:      nonce,
+		Ciphertext: s.aead.Seal(nil, nonce, plaintext, nil),
+	}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "can not encode secrets store")
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return errors.Wrapf(err, "can not write secrets store %s", s.path)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "can not write secrets store %s", s.path)
+	}
+
+	return nil
+}
+
+func newAEAD(key, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2(key, salt, iterations, keySize))
+	if err != nil {
+		return nil, errors.Wrap(err, "can not create cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "can not create cipher")
+	}
+
+	return aead, nil
+}
+
+// pbkdf2 derives a key of keyLen bytes from password as defined by RFC 8018 with HMAC-SHA256
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+	var index [4]byte
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(index[:], uint32(block))
+		prf.Write(index[:])
+		key = prf.Sum(key)
+
+		t := key[len(key)-hashLen:]
+		copy(u, t)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+
+	return key[:keyLen]
+}
@@ -0,0 +1,75 @@
+package secret
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	iterations = 1000
+}
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914, section 11
+	want, err := hex.DecodeString("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783")
+	require.NoError(t, err)
+	require.Equal(t, want, pbkdf2([]byte("passwd"), []byte("salt"), 1, 64))
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	key := []byte("passphrase")
+
+	s, err := Open(path, key)
+	require.NoError(t, err)
+	require.Empty(t, s.Names())
+	require.NoError(t, s.Set("db_password", "s3cr3t"))
+	require.NoError(t, s.Set("api.token", "t0k3n"))
+	require.ErrorIs(t, s.Set("db password", "x"), ErrInvalidName)
+	require.NoError(t, s.Save())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "s3cr3t")
+	require.NotContains(t, string(data), "db_password")
+
+	s, err = Open(path, key)
+	require.NoError(t, err)
+	require.Equal(t, []string{"api.token", "db_password"}, s.Names())
+	value, err := s.Get("db_password")
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", value)
+
+	require.NoError(t, s.Remove("api.token"))
+	require.ErrorIs(t, s.Remove("api.token"), ErrSecretNotSet)
+	require.NoError(t, s.Save())
+	s, err = Open(path, key)
+	require.NoError(t, err)
+	_, err = s.Get("api.token")
+	require.ErrorIs(t, err, ErrSecretNotSet)
+
+	_, err = Open(path, []byte("wrong"))
+	require.ErrorIs(t, err, ErrWrongKey)
+	_, err = Open(path, nil)
+	require.ErrorIs(t, err, ErrNoKey)
+}
+
+func TestReadKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.key")
+	require.NoError(t, os.WriteFile(path, []byte("key material\r\n"), 0o600))
+
+	key, err := ReadKeyFile(path)
+	require.NoError(t, err)
+	require.Equal(t, []byte("key material"), key)
+}
+
+func TestRedact(t *testing.T) {
+	require.Equal(t, "password=**** again ****, port=8080",
+		Redact("password=s3cr3t again s3cr3t, port=8080", []string{"", "s3cr3t", "s3c"}))
+	require.Equal(t, "no secrets", Redact("no secrets", nil))
+}
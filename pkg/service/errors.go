@@ -1,18 +1,64 @@
 package service
 
-import "github.com/pkg/errors"
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
 
 var (
-	// ErrServiceNotExist is hardcoded for proper error handling
-	ErrServiceNotExist                 = errors.New("The specified service does not exist as an installed service.")
+	ErrServiceNotExist                 = errors.New("service does not exist")
+	ErrAccessDenied                    = errors.New("access denied, run as administrator or root")
 	ErrStopTimeoutExceeded             = errors.New("stop timeout exceeded")
+	ErrStartTimeoutExceeded            = errors.New("start timeout exceeded")
 	ErrServiceAlreadyExist             = errors.New("service already exists")
 	ErrFailedToConnectToServiceManager = errors.New("failed to connect to service manager")
+	ErrFailedToOpenService             = errors.New("failed to open service")
 	ErrFailedToCreateService           = errors.New("failed to create service")
+	ErrFailedToReadServiceConfig       = errors.New("failed to read service configuration")
+	ErrFailedToUpdateService           = errors.New("failed to update service configuration")
 	ErrFailedToStartService            = errors.New("failed to start service")
 	ErrFailedToStopService             = errors.New("failed to stop service")
 	ErrFailedToDeleteService           = errors.New("failed to delete service")
 	ErrFailedToRetrieveServiceStatus   = errors.New("failed to retrieve service status")
 	ErrFailedToSendStop                = errors.New("failed to send stop command")
+	ErrFailedToSendReload              = errors.New("failed to send reload command")
 	ErrFailedToGetServiceStatus        = errors.New("failed to get service status")
+	ErrUnsupportedPlatform             = errors.New("no supported service manager on this platform")
 )
+
+// OpError is the error of an operation of the service manager on the service Name.
+// Kind is one of the errors above, Err is the cause reported by the platform, such as a Win32 error code, and may be nil.
+// errors.Is matches both, and ErrAccessDenied when the cause is a permission error.
+type OpError struct {
+	Op   Operation
+	Name string
+	Kind error
+	Err  error
+}
+
+func (e *OpError) Error() string {
+	msg := string(e.Op) + " " + e.Name + ": " + e.Kind.Error()
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+func (e *OpError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+
+	return []error{e.Kind, e.Err}
+}
+
+func (e *OpError) Is(target error) bool {
+	return target == ErrAccessDenied && errors.Is(e.Err, os.ErrPermission)
+}
+
+// opError returns the OpError of op on the service name failed with kind because of err
+func opError(op Operation, name string, kind, err error) error {
+	return &OpError{Op: op, Name: name, Kind: kind, Err: err}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+)
+
+func TestOpError(t *testing.T) {
+	err := opError(OpStart, "svc", ErrFailedToStartService, syscall.EACCES)
+	require.ErrorIs(t, err, ErrFailedToStartService)
+	require.ErrorIs(t, err, syscall.EACCES)
+	require.ErrorIs(t, err, ErrAccessDenied)
+	require.NotErrorIs(t, err, ErrServiceNotExist)
+	require.Equal(t, "start svc: failed to start service: permission denied", err.Error())
+
+	var opErr *OpError
+	require.ErrorAs(t, errors.Wrap(err, "failed to start service"), &opErr)
+	require.Equal(t, OpStart, opErr.Op)
+	require.Equal(t, "svc", opErr.Name)
+	require.Equal(t, syscall.EACCES, opErr.Err)
+
+	err = opError(OpQuery, "svc", ErrServiceNotExist, nil)
+	require.ErrorIs(t, err, ErrServiceNotExist)
+	require.NotErrorIs(t, err, ErrAccessDenied)
+	require.Equal(t, "query svc: service does not exist", err.Error())
+	require.ErrorIs(t, opError(OpInstall, "svc", ErrFailedToCreateService, errors.Wrap(os.ErrPermission, "write unit")), ErrAccessDenied)
+}
+
+func TestWaitStateError(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	m.StartDelay = time.Hour
+	w := newTestService(t, m)
+	require.NoError(t, w.Install(ctx))
+	require.NoError(t, m.Start("svc"))
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err := w.WaitState(ctx, Running)
+	require.ErrorIs(t, err, ErrStartTimeoutExceeded)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	var opErr *OpError
+	require.ErrorAs(t, err, &opErr)
+	require.Equal(t, OpStart, opErr.Op)
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/install"
+)
+
+// FakeManager is an in-memory Manager for tests. Started and stopped services stay in the pending state
+// for StartDelay and StopDelay of its clock, injected failures are returned by the failing operations.
+// Install and Reconfigure reject invalid install options as the platform managers do.
+type FakeManager struct {
+	StartDelay time.Duration
+	StopDelay  time.Duration
+
+	mu       sync.Mutex
+	clock    clock.Clock
+	services map[string]*fakeService
+	failures map[Operation]error
+	calls    []Operation
+}
+
+type fakeService struct {
+	cfg     config.WindowsServiceConfig
+	args    []string
+	state   State
+	since   time.Time
+	reloads int
+	deleted bool
+}
+
+func NewFakeManager(clk clock.Clock) *FakeManager {
+	return &FakeManager{
+		clock:    clk,
+		services: map[string]*fakeService{},
+		failures: map[Operation]error{},
+	}
+}
+
+// Fail makes every following op return err, a nil err clears the failure
+func (m *FakeManager) Fail(op Operation, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		delete(m.failures, op)
+		return
+	}
+	m.failures[op] = err
+}
+
+// Calls returns the operations called so far in order
+func (m *FakeManager) Calls() []Operation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Operation(nil), m.calls...)
+}
+
+// Config returns the configuration and the arguments the service name was installed with
+func (m *FakeManager) Config(name string) (cfg config.WindowsServiceConfig, args []string, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.services[name]
+	if !ok {
+		return config.WindowsServiceConfig{}, nil, false
+	}
+
+	return s.cfg, s.args, true
+}
+
+// Reloads returns the number of reload requests received by the service name
+func (m *FakeManager) Reloads(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.services[name]; ok {
+		return s.reloads
+	}
+
+	return 0
+}
+
+// SetState forces the state of the installed service name, for example to simulate a crash
+func (m *FakeManager) SetState(name string, state State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.services[name]; ok {
+		s.state, s.since = state, m.clock.Now()
+	}
+}
+
+func (m *FakeManager) Install(cfg config.WindowsServiceConfig, args ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call(OpInstall); err != nil {
+		return err
+	}
+	if _, err := install.NewOptions(cfg); err != nil {
+		return opError(OpInstall, cfg.Name, ErrFailedToCreateService, err)
+	}
+	if _, ok := m.services[cfg.Name]; ok {
+		return opError(OpInstall, cfg.Name, ErrServiceAlreadyExist, nil)
+	}
+	m.services[cfg.Name] = &fakeService{cfg: cfg, args: args, state: Stopped, since: m.clock.Now()}
+
+	return nil
+}
+
+func (m *FakeManager) Settings(name string) (install.Settings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.service(OpSettings, name)
+	if err != nil {
+		return install.Settings{}, err
+	}
+
+	return install.NewSettings(s.cfg, s.args...)
+}
+
+func (m *FakeManager) Reconfigure(cfg config.WindowsServiceConfig, args ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.service(OpReconfigure, cfg.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := install.NewOptions(cfg); err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, err)
+	}
+	s.cfg, s.args = cfg, args
+
+	return nil
+}
+
+func (m *FakeManager) Start(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.service(OpStart, name)
+	if err != nil {
+		return err
+	}
+	if s.deleted || s.state != Stopped {
+		return opError(OpStart, name, ErrFailedToStartService, nil)
+	}
+	s.state, s.since = StartPending, m.clock.Now()
+
+	return nil
+}
+
+func (m *FakeManager) Stop(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.service(OpStop, name)
+	if err != nil {
+		return err
+	}
+	if s.state != Running {
+		return opError(OpStop, name, ErrFailedToSendStop, nil)
+	}
+	s.state, s.since = StopPending, m.clock.Now()
+
+	return nil
+}
+
+func (m *FakeManager) Reload(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.service(OpReload, name)
+	if err != nil {
+		return err
+	}
+	if s.state != Running {
+		return opError(OpReload, name, ErrFailedToSendReload, nil)
+	}
+	s.reloads++
+
+	return nil
+}
+
+func (m *FakeManager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.service(OpDelete, name)
+	if err != nil {
+		return err
+	}
+	if s.deleted {
+		// the service control manager rejects deleting a service marked for deletion
+		return opError(OpDelete, name, ErrFailedToDeleteService, nil)
+	}
+	s.deleted = true
+	m.collect(name, s)
+
+	return nil
+}
+
+func (m *FakeManager) Query(name string) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.service(OpQuery, name)
+	if err != nil {
+		return Status{}, err
+	}
+
+	return Status{State: s.state}, nil
+}
+
+// call records op and returns its injected failure, m.mu must be held
+func (m *FakeManager) call(op Operation) error {
+	m.calls = append(m.calls, op)
+	return m.failures[op]
+}
+
+// service records op and returns the service name with its pending state advanced by the clock, m.mu must be held
+func (m *FakeManager) service(op Operation, name string) (*fakeService, error) {
+	if err := m.call(op); err != nil {
+		return nil, err
+	}
+	s, ok := m.services[name]
+	if !ok {
+		return nil, opError(op, name, ErrServiceNotExist, nil)
+	}
+
+	elapsed := m.clock.Now().Sub(s.since)
+	switch {
+	case s.state == StartPending && elapsed >= m.StartDelay:
+		s.state, s.since = Running, s.since.Add(m.StartDelay)
+	case s.state == StopPending && elapsed >= m.StopDelay:
+		s.state, s.since = Stopped, s.since.Add(m.StopDelay)
+	}
+	m.collect(name, s)
+	if _, ok := m.services[name]; !ok {
+		return nil, opError(op, name, ErrServiceNotExist, nil)
+	}
+
+	return s, nil
+}
+
+// collect removes the deleted service name once it is stopped, m.mu must be held
+func (m *FakeManager) collect(name string, s *fakeService) {
+	if s.deleted && s.state == Stopped {
+		delete(m.services, name)
+	}
+}
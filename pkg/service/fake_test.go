@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestFakeManagerStateTransitions(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	m := NewFakeManager(clk)
+	m.StartDelay = time.Second
+	m.StopDelay = 2 * time.Second
+
+	require.NoError(t, m.Install(config.WindowsServiceConfig{Name: "svc"}))
+	require.ErrorIs(t, m.Install(config.WindowsServiceConfig{Name: "svc"}), ErrServiceAlreadyExist)
+	status, err := m.Query("svc")
+	require.NoError(t, err)
+	require.Equal(t, Stopped, status.State)
+
+	require.NoError(t, m.Start("svc"))
+	require.ErrorIs(t, m.Start("svc"), ErrFailedToStartService)
+	status, _ = m.Query("svc")
+	require.Equal(t, StartPending, status.State)
+	clk.Advance(time.Second)
+	status, _ = m.Query("svc")
+	require.Equal(t, Running, status.State)
+
+	require.NoError(t, m.Reload("svc"))
+	require.Equal(t, 1, m.Reloads("svc"))
+
+	require.NoError(t, m.Stop("svc"))
+	clk.Advance(time.Second)
+	status, _ = m.Query("svc")
+	require.Equal(t, StopPending, status.State)
+	clk.Advance(time.Second)
+	status, _ = m.Query("svc")
+	require.Equal(t, Stopped, status.State)
+
+	require.NoError(t, m.Delete("svc"))
+	_, err = m.Query("svc")
+	require.ErrorIs(t, err, ErrServiceNotExist)
+	require.ErrorIs(t, m.Start("missing"), ErrServiceNotExist)
+}
+
+func TestFakeManagerDeleteRunning(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	m := NewFakeManager(clk)
+	m.StopDelay = time.Second
+	require.NoError(t, m.Install(config.WindowsServiceConfig{Name: "svc"}))
+	require.NoError(t, m.Start("svc"))
+
+	require.NoError(t, m.Delete("svc"))
+	require.ErrorIs(t, m.Delete("svc"), ErrFailedToDeleteService)
+	require.NoError(t, m.Stop("svc"))
+	clk.Advance(time.Second)
+	_, err := m.Query("svc")
+	require.ErrorIs(t, err, ErrServiceNotExist)
+}
+
+func TestFakeManagerFailures(t *testing.T) {
+	m := NewFakeManager(clock.System{})
+	failure := errors.New("access denied")
+	m.Fail(OpInstall, failure)
+
+	require.ErrorIs(t, m.Install(config.WindowsServiceConfig{Name: "svc"}), failure)
+	m.Fail(OpInstall, nil)
+	require.NoError(t, m.Install(config.WindowsServiceConfig{Name: "svc"}))
+	require.Equal(t, []Operation{OpInstall, OpInstall}, m.Calls())
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// RunForeground supervises the children in the foreground until an interrupt or SIGTERM is received,
+// SIGHUP reloads the configuration file. The service log and the output of the children, prefixed with their names,
+// are mirrored to console unless it is nil. The returned exitCode is the service-specific exit code.
+func (w *WindowsService) RunForeground(console io.Writer) (exitCode uint32) {
+	if console != nil {
+		w.attachConsole(console)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	commands := make(chan Command)
+	done := make(chan uint32)
+	go func() {
+		done <- w.Supervise(commands, func(Status) {})
+	}()
+
+	for {
+		select {
+		case exitCode = <-done:
+			return exitCode
+		case sig := <-signals:
+			command := CommandStop
+			if sig == syscall.SIGHUP {
+				command = CommandReload
+			}
+			select {
+			case commands <- command:
+			case exitCode = <-done:
+				return exitCode
+			}
+		}
+	}
+}
+
+// attachConsole mirrors the service log and the output of the children to console
+func (w *WindowsService) attachConsole(console io.Writer) {
+	w.console = &syncWriter{w: console}
+	w.log.console = w.console
+	for _, c := range w.Children {
+		w.mirrorChild(c)
+	}
+}
+
+// mirrorChild mirrors the output of a child to the console, prefixed with its name or the name of its binary.
+// Output written to the service log is already mirrored, only the unnamed child gets the prefix there.
+func (w *WindowsService) mirrorChild(c *Child) {
+	name := c.Name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(c.ExecPath), filepath.Ext(c.ExecPath))
+	}
+	console := &linePrefixWriter{prefix: []byte(fmt.Sprintf("[%s] ", name)), w: w.console}
+
+	switch {
+	case c.logFile != nil:
+		c.logFile.console = console
+	case c.Name == "":
+		c.output = w.log.mirroredTo(console)
+	}
+}
@@ -0,0 +1,117 @@
+//go:build !windows
+
+package service
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+// lockedBuffer is a bytes.Buffer safe to read while the service writes to it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// runForeground runs the service in the foreground until outputs are written to the console, then sends SIGTERM
+func runForeground(t *testing.T, w *WindowsService, outputs ...string) string {
+	t.Helper()
+	console := &lockedBuffer{}
+	done := make(chan uint32, 1)
+	go func() {
+		done <- w.RunForeground(console)
+	}()
+
+	require.Eventually(t, func() bool {
+		for _, output := range outputs {
+			if !strings.Contains(console.String(), output) {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	select {
+	case exitCode := <-done:
+		require.Equal(t, uint32(0), exitCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("service did not stop")
+	}
+
+	return console.String()
+}
+
+func TestRunForeground(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "service.log")
+	w := New(config.WindowsServiceConfig{
+		ChildExecPath: "/bin/sh",
+		ChildExecArgs: []string{"-c", "echo serving on :8080; exec sleep 30"},
+		LogFilePath:   logPath,
+	})
+
+	console := runForeground(t, w, "[sh] serving on :8080\n")
+	require.Contains(t, console, "Process started\n")
+	require.Contains(t, console, "Process stopped\n")
+	require.Contains(t, readLog(t, logPath), "serving on :8080\n")
+	require.NotContains(t, readLog(t, logPath), "[sh]")
+}
+
+func TestRunForegroundChildren(t *testing.T) {
+	dir := t.TempDir()
+	w := New(config.WindowsServiceConfig{
+		ParentExecPath: filepath.Join(dir, "service"),
+		LogFilePath:    filepath.Join(dir, "service.log"),
+		Children: []config.Child{
+			{
+				Name:        "api",
+				ExecPath:    "/bin/sh",
+				ExecArgs:    []string{"-c", "echo serving; exec sleep 30"},
+				LogFilePath: filepath.Join(dir, "api.log"),
+			},
+			{
+				Name:     "worker",
+				ExecPath: "/bin/sh",
+				ExecArgs: []string{"-c", "echo working; exec sleep 30"},
+			},
+		},
+	})
+
+	console := runForeground(t, w, "[api] serving\n", "[worker] working\n")
+	require.Contains(t, console, "[api] Process started\n")
+	require.Equal(t, "serving\n", readLog(t, filepath.Join(dir, "api.log")))
+}
+
+func TestLinePrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &linePrefixWriter{prefix: []byte("[api] "), w: &buf}
+
+	for _, s := range []string{"serving", " on :8080\nready\n", "done"} {
+		n, err := w.Write([]byte(s))
+		require.NoError(t, err)
+		require.Equal(t, len(s), n)
+	}
+	require.Equal(t, "[api] serving on :8080\n[api] ready\n[api] done", buf.String())
+}
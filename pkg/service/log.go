@@ -0,0 +1,159 @@
+package service
+
+import (
+	"io"
+	"sync"
+
+	"github.com/natefinch/lumberjack"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+// rotatingLog is a rotating log file whose settings can be replaced while it is written to.
+// Secret values of the configuration are masked in every write, so they never reach the file
+// unless a single value is split across writes.
+type rotatingLog struct {
+	mu     sync.Mutex
+	logger io.WriteCloser
+	redact func(string) string
+	// console mirrors the writes when the service runs in the foreground
+	console io.Writer
+}
+
+func newRotatingLog(cfg config.WindowsServiceConfig, path string) *rotatingLog {
+	return &rotatingLog{
+		logger: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    cfg.LogFileMaxSizeMB,
+			MaxBackups: cfg.LogFileMaxBackups,
+			MaxAge:     cfg.LogFileMaxAgeDays,
+			Compress:   cfg.LogFileCompress,
+		},
+		redact: cfg.Redact,
+	}
+}
+
+// newOutputLog returns the log writing to out instead of a file, it is not rotated
+func newOutputLog(cfg config.WindowsServiceConfig, out io.Writer) *rotatingLog {
+	return &rotatingLog{logger: nopCloser{out}, redact: cfg.Redact}
+}
+
+// nopCloser leaves the writer set by WithOutput open, it belongs to the caller
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func (r *rotatingLog) Write(b []byte) (int, error) {
+	return r.write(b, r.console)
+}
+
+// write writes b to the file and mirrors it to console, if set
+func (r *rotatingLog) write(b []byte, console io.Writer) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	redacted := []byte(r.redact(string(b)))
+	if console != nil {
+		console.Write(redacted)
+	}
+	if _, err := r.logger.Write(redacted); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// mirroredTo returns a writer to the log mirroring its writes to console instead of the console of the log
+func (r *rotatingLog) mirroredTo(console io.Writer) io.Writer {
+	return mirrorWriter{log: r, console: console}
+}
+
+type mirrorWriter struct {
+	log     *rotatingLog
+	console io.Writer
+}
+
+func (m mirrorWriter) Write(b []byte) (int, error) {
+	return m.log.write(b, m.console)
+}
+
+func (r *rotatingLog) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.logger.Close()
+}
+
+// update closes the current file and continues writing with the settings of other
+func (r *rotatingLog) update(other *rotatingLog) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logger.Close()
+	r.logger = other.logger
+	r.redact = other.redact
+}
+
+// setRedact replaces the redactor of the log, the secrets of a reloaded config may differ
+func (r *rotatingLog) setRedact(redact func(string) string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.redact = redact
+}
+
+// prefixWriter prefixes every write with the name of a child, writers are expected to write whole lines
+type prefixWriter struct {
+	prefix string
+	w      io.Writer
+}
+
+func (p prefixWriter) Write(b []byte) (int, error) {
+	if _, err := p.w.Write(append([]byte(p.prefix), b...)); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// linePrefixWriter prefixes every line written to w, lines may be split across writes
+type linePrefixWriter struct {
+	mu        sync.Mutex
+	prefix    []byte
+	w         io.Writer
+	midOfLine bool
+}
+
+func (l *linePrefixWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var out []byte
+	for _, c := range b {
+		if !l.midOfLine {
+			out = append(out, l.prefix...)
+		}
+		out = append(out, c)
+		l.midOfLine = c != '\n'
+	}
+	if _, err := l.w.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// syncWriter serializes the writes to w
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(b)
+}
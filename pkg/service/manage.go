@@ -1,186 +1,368 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
-	"time"
 
-	"github.com/natefinch/lumberjack"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/sys/windows/svc"
-	"golang.org/x/sys/windows/svc/mgr"
 
+	"github.com/edwardezs/win-svc/pkg/child"
+	"github.com/edwardezs/win-svc/pkg/clock"
 	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/health"
+	"github.com/edwardezs/win-svc/pkg/hook"
+	"github.com/edwardezs/win-svc/pkg/install"
+	"github.com/edwardezs/win-svc/pkg/restart"
 )
 
+// New returns the service described by cfg with the default options
 func New(cfg config.WindowsServiceConfig) *WindowsService {
-	logPath := cfg.LogFilePath
-	if logPath == "" || !filepath.IsAbs(logPath) {
-		logPath = filepath.Join(filepath.Dir(cfg.ChildExecPath), logPath)
-	}
+	return NewService(cfg)
+}
 
-	return &WindowsService{
+// NewService returns the service described by cfg configured by opts. The service log goes to the rotating
+// log file of cfg and the management messages to the global zerolog logger unless the options say otherwise.
+func NewService(cfg config.WindowsServiceConfig, opts ...Option) *WindowsService {
+	w := &WindowsService{
 		Name:           cfg.Name,
 		Description:    cfg.Description,
 		ParentExecPath: cfg.ParentExecPath,
-		ChildExecPath:  cfg.ChildExecPath,
-		ChildExecArgs:  cfg.ChildExecArgs,
-		log: &lumberjack.Logger{
-			Filename:   logPath,
-			MaxSize:    cfg.LogFileMaxSizeMB,
-			MaxBackups: cfg.LogFileMaxBackups,
-			MaxAge:     cfg.LogFileMaxAgeDays,
-		},
+		Manager:        platformManager(cfg),
+		cfg:            cfg,
+		clock:          clock.System{},
+		logger:         &log.Logger,
 	}
-}
-
-func (w *WindowsService) Start() error {
-	scm, err := mgr.Connect()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to connect to service manager")
-		return ErrFailedToConnectToServiceManager
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.output != nil {
+		w.log = newOutputLog(cfg, w.output)
+	} else {
+		w.log = newRotatingLog(cfg, serviceLogPath(cfg))
 	}
-	defer scm.Disconnect()
 
-	service, err := scm.OpenService(w.Name)
+	childCfgs, err := cfg.ChildConfigs()
 	if err != nil {
-		log.Error().Err(err).Msgf("Service %s is not installed", w.Name)
-		return ErrServiceNotExist
+		if w.cfgErr == nil {
+			w.cfgErr = err
+		}
+		return w
 	}
-	defer service.Close()
-
-	if err := service.Start(); err != nil {
-		log.Error().Err(err).Msg("Failed to start Windows service")
-		return ErrFailedToStartService
+	for _, c := range childCfgs {
+		ch, err := w.newChild(cfg, c)
+		if w.cfgErr == nil {
+			w.cfgErr = err
+		}
+		w.Children = append(w.Children, ch)
 	}
-	log.Info().Msgf("Service %s started", w.Name)
 
-	return nil
+	return w
 }
 
-func (w *WindowsService) Run() {
-	if err := svc.Run(w.Name, w); err != nil {
-		w.log.Write([]byte(fmt.Sprintf("Failed to start service: %s\n", err.Error())))
+// serviceLogPath returns the path of the service log, by default in the directory of the child process
+// or of the service binary when several children are set
+func serviceLogPath(cfg config.WindowsServiceConfig) string {
+	logDir := filepath.Dir(cfg.ChildExecPath)
+	if len(cfg.Children) > 0 {
+		logDir = filepath.Dir(cfg.ParentExecPath)
 	}
+	if cfg.LogFilePath == "" || !filepath.IsAbs(cfg.LogFilePath) {
+		return filepath.Join(logDir, cfg.LogFilePath)
+	}
+
+	return cfg.LogFilePath
 }
 
-func (w *WindowsService) Stop() error {
-	scm, err := mgr.Connect()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to connect to service manager")
-		return ErrFailedToConnectToServiceManager
+// newChild parses the settings of a child, the output of the child goes to its own log file if it has one
+func (w *WindowsService) newChild(cfg config.WindowsServiceConfig, c config.Child) (*Child, error) {
+	workDir := c.WorkDir
+	if workDir == "" {
+		workDir = filepath.Dir(c.ExecPath)
 	}
-	defer scm.Disconnect()
 
-	service, err := scm.OpenService(w.Name)
-	if err != nil {
-		log.Error().Err(err).Msgf("Service %s is not installed", w.Name)
-		return ErrServiceNotExist
+	var restartPolicy restart.Policy
+	var cfgErr error
+	if w.restartPolicy != nil {
+		restartPolicy = *w.restartPolicy
+	} else {
+		restartPolicy, cfgErr = restart.NewPolicy(c.RestartPolicy)
+	}
+	crashLoop, err := restart.NewCrashLoopPolicy(c.CrashLoop)
+	if cfgErr == nil {
+		cfgErr = err
+	}
+	stopSequence, err := child.NewStopSequence(c.Stop)
+	if cfgErr == nil {
+		cfgErr = err
+	}
+	healthChecks, err := health.NewChecks(c.HealthChecks)
+	if cfgErr == nil {
+		cfgErr = err
+	}
+	readiness, err := health.NewGate(c.Readiness)
+	if cfgErr == nil {
+		cfgErr = err
+	}
+	hooks, err := hook.New(c.Hooks)
+	if cfgErr == nil {
+		cfgErr = err
+	}
+	if cfgErr != nil && c.Name != "" {
+		cfgErr = errors.Wrapf(cfgErr, "child %s", c.Name)
 	}
-	defer service.Close()
 
-	status, err := service.Control(svc.Stop)
+	// the events are whole lines, the output of the child comes in arbitrary chunks
+	var events, output io.Writer = w.log, w.log
+	if c.Name != "" {
+		events = prefixWriter{prefix: fmt.Sprintf("[%s] ", c.Name), w: w.log}
+		output = &linePrefixWriter{prefix: []byte(fmt.Sprintf("[%s] ", c.Name)), w: w.log}
+	}
+	var logFile *rotatingLog
+	if c.LogFilePath != "" {
+		logPath := c.LogFilePath
+		if !filepath.IsAbs(logPath) {
+			logPath = filepath.Join(filepath.Dir(c.ExecPath), logPath)
+		}
+		logFile = newRotatingLog(cfg, logPath)
+		output = logFile
+	}
+
+	ch := &Child{
+		Name:     c.Name,
+		ExecPath: c.ExecPath,
+		ExecArgs: c.ExecArgs,
+		WorkDir:  workDir,
+		Env: child.Env{
+			Inherit: c.InheritEnv == nil || *c.InheritEnv,
+			Files:   c.EnvFiles,
+			Vars:    c.Env,
+		},
+		RestartPolicy: restartPolicy,
+		CrashLoop:     crashLoop,
+		StopSequence:  stopSequence,
+		HealthChecks:  healthChecks,
+		Readiness:     readiness,
+		Hooks:         hooks,
+		DependsOn:     c.DependsOn,
+		Critical:      c.Critical,
+		output:        output,
+		events:        events,
+		logFile:       logFile,
+	}
+	if w.console != nil {
+		w.mirrorChild(ch)
+	}
+
+	return ch, cfgErr
+}
+
+// Status returns the state of the service reported by the service manager together with the runtime status
+// of its last run kept in the status file. The process ids of a stopped service are cleared.
+func (w *WindowsService) Status(ctx context.Context) (Report, error) {
+	if err := ctx.Err(); err != nil {
+		return Report{}, err
+	}
+	status, err := w.Manager.Query(w.Name)
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed to send stop command to service %s", w.Name)
-		return ErrFailedToSendStop
+		return Report{}, err
 	}
 
-	timeout := time.Now().Add(changeStateTimeout)
-	for status.State != svc.Stopped {
-		if timeout.Before(time.Now()) {
-			log.Error().Msg("Timeout waiting for service to stop exceeded")
-			return ErrStopTimeoutExceeded
+	report, err := ReadReport(w.cfg)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Report{}, opError(OpQuery, w.Name, ErrFailedToGetServiceStatus, errors.Wrap(err, "read status file"))
+	}
+	report.State = status.State
+
+	now := w.clock.Now()
+	if report.State == Stopped {
+		report.PID = 0
+	}
+	for i := range report.Children {
+		c := &report.Children[i]
+		if report.State == Stopped {
+			c.PID = 0
 		}
-		status, err = service.Query()
-		if err != nil {
-			log.Error().Err(err).Msg("Could not retrieve service status")
-			return ErrFailedToGetServiceStatus
+		if c.PID != 0 && c.StartedAt != nil {
+			c.UptimeMs = now.Sub(*c.StartedAt).Milliseconds()
 		}
 	}
-	log.Info().Msgf("Service %s stopped", w.Name)
+
+	return report, nil
+}
+
+// Start starts the service and waits until it runs, ctx bounds the wait together with Timeout
+func (w *WindowsService) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := w.Manager.Start(w.Name); err != nil {
+		return err
+	}
+	if w.NoWait {
+		w.logger.Info().Msgf("Service %s is starting", w.Name)
+		return nil
+	}
+	if err := w.wait(ctx, Running); err != nil {
+		return err
+	}
+	w.logger.Info().Msgf("Service %s started", w.Name)
 
 	return nil
 }
 
-func (w *WindowsService) Install() error {
-	scm, err := mgr.Connect()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to connect to service manager")
-		return ErrFailedToConnectToServiceManager
+// Stop stops the service and waits until it is stopped, ctx bounds the wait together with Timeout
+func (w *WindowsService) Stop(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	defer scm.Disconnect()
+	if err := w.Manager.Stop(w.Name); err != nil {
+		return err
+	}
+	if w.NoWait {
+		w.logger.Info().Msgf("Service %s is stopping", w.Name)
+		return nil
+	}
+	if err := w.wait(ctx, Stopped); err != nil {
+		return err
+	}
+	w.logger.Info().Msgf("Service %s stopped", w.Name)
+
+	return nil
+}
 
-	service, err := scm.OpenService(w.Name)
-	if err == nil {
-		service.Close()
-		log.Error().Msgf("Service %s already installed", w.Name)
-		return ErrServiceAlreadyExist
+// Restart stops the service if it is running and starts it again
+func (w *WindowsService) Restart(ctx context.Context) error {
+	if err := w.stopRunning(ctx, true); err != nil {
+		return err
 	}
 
-	service, err = scm.CreateService(w.Name, w.ParentExecPath, mgr.Config{
-		DisplayName: w.Name,
-		Description: w.Description,
-	})
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed to create service %s", w.Name)
-		return ErrFailedToCreateService
+	return w.Start(ctx)
+}
+
+// Reload asks the running service to reload its configuration file
+func (w *WindowsService) Reload(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	defer service.Close()
+	if err := w.Manager.Reload(w.Name); err != nil {
+		return err
+	}
+	w.logger.Info().Msgf("Service %s asked to reload its configuration, see the service log for the result", w.Name)
 
-	log.Info().Msgf("Service %s installed", w.Name)
+	return nil
+}
+
+// Install registers the service with the service manager
+func (w *WindowsService) Install(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := w.Manager.Install(w.cfg, w.installArgs()...); err != nil {
+		return err
+	}
+	w.logger.Info().Msgf("Service %s installed", w.Name)
 
 	return nil
 }
 
-func (w *WindowsService) Delete() error {
-	scm, err := mgr.Connect()
+// Reconfigure compares the settings of the installed service with the config and applies the changes,
+// with dryRun set the changes are only returned. The changes take effect on the next start of the service.
+func (w *WindowsService) Reconfigure(ctx context.Context, dryRun bool) ([]install.Change, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	installed, err := w.Manager.Settings(w.Name)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to connect to service manager")
-		return ErrFailedToConnectToServiceManager
+		return nil, err
 	}
-	defer scm.Disconnect()
-
-	service, err := scm.OpenService(w.Name)
+	next, err := install.NewSettings(w.cfg, w.installArgs()...)
 	if err != nil {
-		log.Error().Err(err).Msgf("Service %s is not installed", w.Name)
-		return ErrServiceNotExist
+		return nil, opError(OpReconfigure, w.Name, ErrFailedToUpdateService, err)
 	}
-	defer service.Close()
 
-	status, err := service.Query()
-	if err != nil {
-		log.Error().Err(err).Msg("Could not retrieve service status")
-		return ErrFailedToGetServiceStatus
+	changes := install.Diff(installed, next)
+	if len(changes) == 0 || dryRun {
+		return changes, nil
+	}
+	if err := w.Manager.Reconfigure(w.cfg, w.installArgs()...); err != nil {
+		return nil, err
 	}
+	w.logger.Info().Msgf("Service %s reconfigured, %d settings changed", w.Name, len(changes))
 
-	if status.State == svc.Running {
-		log.Info().Msgf("Service %s is running, stopping", w.Name)
-		timeout := time.Now().Add(changeStateTimeout)
-		status, err := service.Control(svc.Stop)
-		if err != nil {
-			log.Error().Err(err).Msgf("Failed to send stop command to service %s", w.Name)
-			return ErrFailedToSendStop
-		}
-		for status.State != svc.Stopped {
-			if timeout.Before(time.Now()) {
-				log.Error().Msg("Timeout waiting for service to stop exceeded")
-				return ErrStopTimeoutExceeded
-			}
-			status, err = service.Query()
-			if err != nil {
-				log.Error().Err(err).Msg("Could not retrieve service status")
-				return ErrFailedToGetServiceStatus
-			}
+	return changes, nil
+}
+
+// Ensure installs the service if it is not installed and reconfigures it otherwise,
+// with dryRun set nothing is changed
+func (w *WindowsService) Ensure(ctx context.Context, dryRun bool) (installed bool, changes []install.Change, err error) {
+	changes, err = w.Reconfigure(ctx, dryRun)
+	if !errors.Is(err, ErrServiceNotExist) {
+		return false, changes, err
+	}
+	if dryRun {
+		if _, err := install.NewOptions(w.cfg); err != nil {
+			return true, nil, opError(OpInstall, w.Name, ErrFailedToCreateService, err)
 		}
-		log.Info().Msgf("Service %s stopped", w.Name)
+		return true, nil, nil
+	}
+	if err := w.Install(ctx); err != nil {
+		return false, nil, err
 	}
 
-	if err = service.Delete(); err != nil {
-		log.Error().Err(err).Msgf("Failed to delete service %s", w.Name)
-		return ErrFailedToDeleteService
+	return true, nil, nil
+}
+
+// Delete stops the service if it is running and unregisters it, ctx bounds the wait for the stop together with Timeout
+func (w *WindowsService) Delete(ctx context.Context) error {
+	if err := w.stopRunning(ctx, !w.NoWait); err != nil {
+		return err
+	}
+	if err := w.Manager.Delete(w.Name); err != nil {
+		return err
 	}
+	w.logger.Info().Msgf("Service %s uninstalled", w.Name)
 
-	log.Info().Msgf("Service %s uninstalled", w.Name)
+	return nil
+}
+
+// stopRunning stops the service if it is not stopped, waiting until it is stopped if wait is set
+func (w *WindowsService) stopRunning(ctx context.Context, wait bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	status, err := w.Manager.Query(w.Name)
+	if err != nil {
+		return err
+	}
+	if status.State == Stopped {
+		return nil
+	}
+
+	w.logger.Info().Msgf("Service %s is %s, stopping", w.Name, status.State)
+	if status.State != StopPending {
+		if err := w.Manager.Stop(w.Name); err != nil {
+			return err
+		}
+	}
+	if !wait {
+		return nil
+	}
+	if err := w.wait(ctx, Stopped); err != nil {
+		return err
+	}
+	w.logger.Info().Msgf("Service %s stopped", w.Name)
 
 	return nil
 }
+
+// wait waits until the service reaches state within Timeout or until ctx is done
+func (w *WindowsService) wait(ctx context.Context, state State) error {
+	ctx, cancel := w.waitContext(ctx)
+	defer cancel()
+
+	return w.WaitState(ctx, state)
+}
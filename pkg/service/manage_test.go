@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/install"
+)
+
+func newTestService(t *testing.T, m *FakeManager) *WindowsService {
+	w := New(config.WindowsServiceConfig{
+		Name:           "svc",
+		Description:    "Test service",
+		ParentExecPath: "/usr/bin/service",
+		ChildExecPath:  "/bin/sh",
+		LogFilePath:    t.TempDir() + "/service.log",
+	})
+	w.Manager = m
+
+	return w
+}
+
+func TestServiceLifecycle(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	w := newTestService(t, m)
+
+	require.NoError(t, w.Install(ctx))
+	cfg, _, ok := m.Config("svc")
+	require.True(t, ok)
+	require.Equal(t, "Test service", cfg.Description)
+	require.ErrorIs(t, w.Install(ctx), ErrServiceAlreadyExist)
+
+	require.NoError(t, w.Start(ctx))
+	require.NoError(t, w.Reload(ctx))
+	require.NoError(t, w.Stop(ctx))
+	status, err := m.Query("svc")
+	require.NoError(t, err)
+	require.Equal(t, Stopped, status.State)
+
+	require.NoError(t, w.Start(ctx))
+	require.NoError(t, w.Delete(ctx))
+	_, err = m.Query("svc")
+	require.ErrorIs(t, err, ErrServiceNotExist)
+	require.Equal(t, []Operation{
+		OpInstall, OpInstall,
+		OpStart, OpQuery,
+		OpReload,
+		OpStop, OpQuery, OpQuery,
+		OpStart, OpQuery,
+		OpQuery, OpStop, OpQuery, OpDelete, OpQuery,
+	}, m.Calls())
+}
+
+func TestServiceInstallOptions(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	w := newTestService(t, m)
+	w.cfg.StartType = "auto"
+	err := w.Install(ctx)
+	var opErr *OpError
+	require.True(t, errors.As(err, &opErr))
+	require.Equal(t, OpInstall, opErr.Op)
+	require.Equal(t, "svc", opErr.Name)
+	require.ErrorIs(t, err, ErrFailedToCreateService)
+	require.ErrorIs(t, err, install.ErrInvalidStartType)
+	require.Equal(t, []Operation{OpInstall}, m.Calls())
+
+	w.cfg.StartType = "automatic"
+	w.cfg.Account = "NetworkService"
+	require.NoError(t, w.Install(ctx))
+}
+
+func TestInstallArgs(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	w := newTestService(t, m)
+	w.ConfigPath = "/etc/svc/service.config.json"
+	w.ConfigOverlays = []string{"/etc/svc/service.config.prod.json"}
+
+	require.NoError(t, w.Install(ctx))
+	_, args, ok := m.Config("svc")
+	require.True(t, ok)
+	want := []string{"-config", "/etc/svc/service.config.json", "-overlay", "/etc/svc/service.config.prod.json"}
+	if runtime.GOOS != "windows" {
+		// systemd runs the supervisor in the foreground
+		want = append(want, "run")
+	}
+	require.Equal(t, want, args)
+}
+
+func TestServiceReconfigure(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	w := newTestService(t, m)
+	_, err := w.Reconfigure(ctx, false)
+	require.ErrorIs(t, err, ErrServiceNotExist)
+	require.NoError(t, w.Install(ctx))
+
+	changes, err := w.Reconfigure(ctx, false)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	w.cfg.Description = "Changed"
+	w.cfg.StartType = "automatic"
+	want := []install.Change{
+		{Field: "description", From: `"Test service"`, To: `"Changed"`},
+		{Field: "startType", From: "manual", To: "automatic"},
+	}
+	changes, err = w.Reconfigure(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, want, changes)
+	require.NotContains(t, m.Calls(), OpReconfigure)
+
+	changes, err = w.Reconfigure(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, want, changes)
+	cfg, _, _ := m.Config("svc")
+	require.Equal(t, "Changed", cfg.Description)
+
+	changes, err = w.Reconfigure(ctx, false)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	w.cfg.StartType = "auto"
+	_, err = w.Reconfigure(ctx, false)
+	var opErr *OpError
+	require.True(t, errors.As(err, &opErr))
+	require.Equal(t, OpReconfigure, opErr.Op)
+	require.ErrorIs(t, err, ErrFailedToUpdateService)
+	require.ErrorIs(t, err, install.ErrInvalidStartType)
+}
+
+func TestServiceEnsure(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	w := newTestService(t, m)
+
+	installed, _, err := w.Ensure(ctx, true)
+	require.NoError(t, err)
+	require.True(t, installed)
+	require.NotContains(t, m.Calls(), OpInstall)
+
+	installed, _, err = w.Ensure(ctx, false)
+	require.NoError(t, err)
+	require.True(t, installed)
+	require.Contains(t, m.Calls(), OpInstall)
+
+	w.cfg.DisplayName = "Service"
+	installed, changes, err := w.Ensure(ctx, false)
+	require.NoError(t, err)
+	require.False(t, installed)
+	require.Equal(t, []install.Change{{Field: "displayName", From: `"svc"`, To: `"Service"`}}, changes)
+
+	installed, changes, err = w.Ensure(ctx, false)
+	require.NoError(t, err)
+	require.False(t, installed)
+	require.Empty(t, changes)
+}
+
+func TestServiceNotInstalled(t *testing.T) {
+	ctx := context.Background()
+	w := newTestService(t, NewFakeManager(clock.System{}))
+
+	require.ErrorIs(t, w.Start(ctx), ErrServiceNotExist)
+	require.ErrorIs(t, w.Stop(ctx), ErrServiceNotExist)
+	require.ErrorIs(t, w.Delete(ctx), ErrServiceNotExist)
+}
+
+func TestServiceWaitsForState(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	m.StartDelay = 150 * time.Millisecond
+	m.StopDelay = 150 * time.Millisecond
+	w := newTestService(t, m)
+	require.NoError(t, w.Install(ctx))
+
+	require.NoError(t, w.Start(ctx))
+	status, _ := m.Query("svc")
+	require.Equal(t, Running, status.State)
+
+	require.NoError(t, w.Restart(ctx))
+	status, _ = m.Query("svc")
+	require.Equal(t, Running, status.State)
+
+	w.NoWait = true
+	require.NoError(t, w.Stop(ctx))
+	status, _ = m.Query("svc")
+	require.Equal(t, StopPending, status.State)
+	require.NoError(t, w.Restart(ctx))
+	status, _ = m.Query("svc")
+	require.Equal(t, StartPending, status.State)
+}
+
+func TestServiceWaitTimeout(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	m.StartDelay = time.Hour
+	m.StopDelay = time.Hour
+	w := newTestService(t, m)
+	w.Timeout = 50 * time.Millisecond
+	require.NoError(t, w.Install(ctx))
+
+	require.ErrorIs(t, w.Start(ctx), ErrStartTimeoutExceeded)
+	m.SetState("svc", Running)
+	require.ErrorIs(t, w.Stop(ctx), ErrStopTimeoutExceeded)
+	require.ErrorIs(t, w.Delete(ctx), ErrStopTimeoutExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, w.WaitState(ctx, Stopped), context.Canceled)
+}
+
+func TestServiceStoppedWhileStarting(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	m.StartDelay = time.Hour
+	w := newTestService(t, m)
+	require.NoError(t, w.Install(ctx))
+	require.NoError(t, m.Start("svc"))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		m.SetState("svc", Stopped)
+	}()
+	require.ErrorIs(t, w.WaitState(context.Background(), Running), ErrFailedToStartService)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/install"
+)
+
+// Manager installs and controls services of the platform service manager.
+// Operations on a service that is not installed return ErrServiceNotExist.
+type Manager interface {
+	// Install registers the service described by cfg running cfg.ParentExecPath with args,
+	// it returns ErrServiceAlreadyExist if it is installed
+	Install(cfg config.WindowsServiceConfig, args ...string) error
+	// Settings returns the settings the service manager keeps for the installed service
+	Settings(name string) (install.Settings, error)
+	// Reconfigure replaces the settings of the installed service with the settings of cfg running cfg.ParentExecPath with args
+	Reconfigure(cfg config.WindowsServiceConfig, args ...string) error
+	// Start asks the service manager to start the service without waiting for it to run
+	Start(name string) error
+	// Stop sends the stop request to the service without waiting for it to stop
+	Stop(name string) error
+	// Reload asks the running service to reload its configuration file
+	Reload(name string) error
+	// Delete unregisters the service, it is removed once it stops
+	Delete(name string) error
+	// Query returns the current status of the service
+	Query(name string) (Status, error)
+}
+
+// Operation names a Manager operation, it is reported by OpError and used to inject failures into a FakeManager
+type Operation string
+
+const (
+	OpInstall     Operation = "install"
+	OpSettings    Operation = "settings"
+	OpReconfigure Operation = "reconfigure"
+	OpStart       Operation = "start"
+	OpStop        Operation = "stop"
+	OpReload      Operation = "reload"
+	OpDelete      Operation = "delete"
+	OpQuery       Operation = "query"
+)
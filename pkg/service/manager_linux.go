@@ -0,0 +1,13 @@
+package service
+
+import "github.com/edwardezs/win-svc/pkg/config"
+
+// NewManager returns the Manager of the platform service manager
+func NewManager() Manager {
+	return NewSystemdManager(config.Systemd{})
+}
+
+// platformManager returns the Manager of the platform service manager configured by cfg
+func platformManager(cfg config.WindowsServiceConfig) Manager {
+	return NewSystemdManager(cfg.Systemd)
+}
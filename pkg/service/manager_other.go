@@ -0,0 +1,36 @@
+//go:build !windows && !linux
+
+package service
+
+import (
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/install"
+)
+
+// unsupportedManager is the Manager of platforms without a supported service manager
+type unsupportedManager struct{}
+
+// NewManager returns the Manager of the platform service manager
+func NewManager() Manager {
+	return unsupportedManager{}
+}
+
+// platformManager returns the Manager of the platform service manager configured by cfg
+func platformManager(config.WindowsServiceConfig) Manager {
+	return unsupportedManager{}
+}
+
+func (unsupportedManager) Install(config.WindowsServiceConfig, ...string) error {
+	return ErrUnsupportedPlatform
+}
+func (unsupportedManager) Settings(string) (install.Settings, error) {
+	return install.Settings{}, ErrUnsupportedPlatform
+}
+func (unsupportedManager) Reconfigure(config.WindowsServiceConfig, ...string) error {
+	return ErrUnsupportedPlatform
+}
+func (unsupportedManager) Start(string) error           { return ErrUnsupportedPlatform }
+func (unsupportedManager) Stop(string) error            { return ErrUnsupportedPlatform }
+func (unsupportedManager) Reload(string) error          { return ErrUnsupportedPlatform }
+func (unsupportedManager) Delete(string) error          { return ErrUnsupportedPlatform }
+func (unsupportedManager) Query(string) (Status, error) { return Status{}, ErrUnsupportedPlatform }
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/install"
+)
+
+// scmManager is the Manager of the Windows service control manager
+type scmManager struct{}
+
+// NewManager returns the Manager of the platform service manager
+func NewManager() Manager {
+	return scmManager{}
+}
+
+// platformManager returns the Manager of the platform service manager configured by cfg
+func platformManager(config.WindowsServiceConfig) Manager {
+	return scmManager{}
+}
+
+// open connects to the service control manager and opens the service name for op, close releases both
+func (scmManager) open(op Operation, name string) (service *mgr.Service, close func(), err error) {
+	scm, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, opError(op, name, ErrFailedToConnectToServiceManager, err)
+	}
+
+	service, err = scm.OpenService(name)
+	if err != nil {
+		scm.Disconnect()
+		return nil, nil, openError(op, name, err)
+	}
+
+	return service, func() {
+		service.Close()
+		scm.Disconnect()
+	}, nil
+}
+
+// openError returns the OpError of op failed to open the service name
+func openError(op Operation, name string, err error) error {
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return opError(op, name, ErrServiceNotExist, err)
+	}
+
+	return opError(op, name, ErrFailedToOpenService, err)
+}
+
+// mgrConfig returns the service manager settings of the service described by cfg together with its parsed install options
+func mgrConfig(cfg config.WindowsServiceConfig) (mgr.Config, install.Options, error) {
+	opts, err := install.NewOptions(cfg)
+	if err != nil {
+		return mgr.Config{}, opts, err
+	}
+
+	return mgr.Config{
+		DisplayName:      opts.DisplayName,
+		Description:      cfg.Description,
+		StartType:        uint32(opts.StartType),
+		DelayedAutoStart: opts.DelayedStart,
+		ServiceStartName: opts.Account,
+		Password:         opts.Password,
+		Dependencies:     opts.Dependencies,
+	}, opts, nil
+}
+
+func (scmManager) Install(cfg config.WindowsServiceConfig, args ...string) error {
+	c, opts, err := mgrConfig(cfg)
+	if err != nil {
+		return opError(OpInstall, cfg.Name, ErrFailedToCreateService, err)
+	}
+
+	scm, err := mgr.Connect()
+	if err != nil {
+		return opError(OpInstall, cfg.Name, ErrFailedToConnectToServiceManager, err)
+	}
+	defer scm.Disconnect()
+
+	service, err := scm.OpenService(cfg.Name)
+	if err == nil {
+		service.Close()
+		return opError(OpInstall, cfg.Name, ErrServiceAlreadyExist, nil)
+	}
+	if !errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return opError(OpInstall, cfg.Name, ErrFailedToOpenService, err)
+	}
+
+	service, err = scm.CreateService(cfg.Name, cfg.ParentExecPath, c, args...)
+	if err != nil {
+		return opError(OpInstall, cfg.Name, ErrFailedToCreateService, err)
+	}
+	defer service.Close()
+
+	if err := setRecovery(service, opts.Recovery); err != nil {
+		service.Delete()
+		return opError(OpInstall, cfg.Name, ErrFailedToCreateService, errors.Wrap(err, "set recovery actions"))
+	}
+
+	return nil
+}
+
+// setRecovery replaces the failure actions of service with r
+func setRecovery(service *mgr.Service, r install.Recovery) error {
+	if len(r.Actions) == 0 {
+		if err := service.ResetRecoveryActions(); err != nil {
+			return err
+		}
+	} else {
+		actions := make([]mgr.RecoveryAction, len(r.Actions))
+		for i, a := range r.Actions {
+			actions[i] = mgr.RecoveryAction{Type: int(a.Type), Delay: a.Delay}
+		}
+		if slices.ContainsFunc(r.Actions, func(a install.RecoveryAction) bool { return a.Type == install.RecoveryReboot }) {
+			// the service control manager only accepts the reboot action from a caller allowed to shut down the system
+			if err := enableShutdownPrivilege(); err != nil {
+				return err
+			}
+		}
+		if err := service.SetRecoveryActions(actions, uint32(r.ResetPeriod/time.Second)); err != nil {
+			return err
+		}
+	}
+	if err := service.SetRecoveryCommand(r.Command); err != nil {
+		return err
+	}
+	if err := service.SetRebootMessage(r.RebootMessage); err != nil {
+		return err
+	}
+
+	return service.SetRecoveryActionsOnNonCrashFailures(r.OnNonCrashFailures)
+}
+
+// recovery returns the failure actions of service
+func recovery(service *mgr.Service) (install.Recovery, error) {
+	var r install.Recovery
+	actions, err := service.RecoveryActions()
+	if err != nil {
+		return r, err
+	}
+	for _, a := range actions {
+		r.Actions = append(r.Actions, install.RecoveryAction{Type: install.RecoveryActionType(a.Type), Delay: a.Delay})
+	}
+	if len(r.Actions) > 0 {
+		resetPeriod, err := service.ResetPeriod()
+		if err != nil {
+			return r, err
+		}
+		r.ResetPeriod = time.Duration(resetPeriod) * time.Second
+	}
+	if r.Command, err = service.RecoveryCommand(); err != nil {
+		return r, err
+	}
+	if r.RebootMessage, err = service.RebootMessage(); err != nil {
+		return r, err
+	}
+	r.OnNonCrashFailures, err = service.RecoveryActionsOnNonCrashFailures()
+
+	return r, err
+}
+
+// enableShutdownPrivilege enables the shutdown privilege of the current process, which administrators hold disabled
+func enableShutdownPrivilege() error {
+	var token windows.Token
+	if err := windows.OpenProcessToken(windows.CurrentProcess(), windows.TOKEN_ADJUST_PRIVILEGES|windows.TOKEN_QUERY, &token); err != nil {
+		return err
+	}
+	defer token.Close()
+
+	privileges := windows.Tokenprivileges{PrivilegeCount: 1}
+	privileges.Privileges[0].Attributes = windows.SE_PRIVILEGE_ENABLED
+	if err := windows.LookupPrivilegeValue(nil, windows.StringToUTF16Ptr("SeShutdownPrivilege"), &privileges.Privileges[0].Luid); err != nil {
+		return err
+	}
+
+	return windows.AdjustTokenPrivileges(token, false, &privileges, uint32(unsafe.Sizeof(privileges)), nil, nil)
+}
+
+// localSystem is the account name the service control manager reports for services running as LocalSystem
+const localSystem = "LocalSystem"
+
+func (m scmManager) Settings(name string) (install.Settings, error) {
+	service, close, err := m.open(OpSettings, name)
+	if err != nil {
+		return install.Settings{}, err
+	}
+	defer close()
+
+	c, err := service.Config()
+	if err != nil {
+		return install.Settings{}, opError(OpSettings, name, ErrFailedToReadServiceConfig, err)
+	}
+	command, err := windows.DecomposeCommandLine(c.BinaryPathName)
+	if err == nil && len(command) == 0 {
+		err = errors.Errorf("empty command line")
+	}
+	if err != nil {
+		return install.Settings{}, opError(OpSettings, name, ErrFailedToReadServiceConfig, err)
+	}
+	account := c.ServiceStartName
+	if strings.EqualFold(account, localSystem) {
+		account = ""
+	}
+
+	settings := install.Settings{
+		DisplayName:  c.DisplayName,
+		Description:  c.Description,
+		ExecPath:     command[0],
+		StartType:    install.StartType(c.StartType),
+		DelayedStart: c.DelayedAutoStart,
+		Account:      account,
+		Dependencies: c.Dependencies,
+	}
+	if settings.Recovery, err = recovery(service); err != nil {
+		return install.Settings{}, opError(OpSettings, name, ErrFailedToReadServiceConfig, errors.Wrap(err, "read recovery actions"))
+	}
+	if len(command) > 1 {
+		settings.Args = command[1:]
+	}
+
+	return settings, nil
+}
+
+func (m scmManager) Reconfigure(cfg config.WindowsServiceConfig, args ...string) error {
+	next, opts, err := mgrConfig(cfg)
+	if err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, err)
+	}
+
+	service, close, err := m.open(OpReconfigure, cfg.Name)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	c, err := service.Config()
+	if err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToReadServiceConfig, err)
+	}
+
+	// the command line is built the same way CreateService builds it
+	command := syscall.EscapeArg(cfg.ParentExecPath)
+	for _, arg := range args {
+		command += " " + syscall.EscapeArg(arg)
+	}
+	c.BinaryPathName = command
+	c.DisplayName = next.DisplayName
+	c.Description = next.Description
+	c.StartType = next.StartType
+	c.DelayedAutoStart = next.DelayedAutoStart
+	c.Password = next.Password
+	c.Dependencies = next.Dependencies
+	// an empty account leaves the account unchanged, LocalSystem has to be named
+	c.ServiceStartName = next.ServiceStartName
+	if c.ServiceStartName == "" {
+		c.ServiceStartName = localSystem
+	}
+
+	if len(c.Dependencies) == 0 {
+		// UpdateConfig leaves the dependencies unchanged when there are none, an empty list clears them
+		empty := []uint16{0, 0}
+		err := windows.ChangeServiceConfig(service.Handle, windows.SERVICE_NO_CHANGE, windows.SERVICE_NO_CHANGE,
+			windows.SERVICE_NO_CHANGE, nil, nil, nil, &empty[0], nil, nil, nil)
+		if err != nil {
+			return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, errors.Wrap(err, "clear dependencies"))
+		}
+	}
+	if err := service.UpdateConfig(c); err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, err)
+	}
+	if err := setRecovery(service, opts.Recovery); err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, errors.Wrap(err, "set recovery actions"))
+	}
+
+	return nil
+}
+
+func (m scmManager) Start(name string) error {
+	service, close, err := m.open(OpStart, name)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	if err := service.Start(); err != nil {
+		return opError(OpStart, name, ErrFailedToStartService, err)
+	}
+
+	return nil
+}
+
+func (m scmManager) Stop(name string) error {
+	service, close, err := m.open(OpStop, name)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	if _, err := service.Control(svc.Stop); err != nil {
+		return opError(OpStop, name, ErrFailedToSendStop, err)
+	}
+
+	return nil
+}
+
+func (m scmManager) Reload(name string) error {
+	service, close, err := m.open(OpReload, name)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	if _, err := service.Control(ReloadControlCode); err != nil {
+		return opError(OpReload, name, ErrFailedToSendReload, err)
+	}
+
+	return nil
+}
+
+func (m scmManager) Delete(name string) error {
+	service, close, err := m.open(OpDelete, name)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	if err := service.Delete(); err != nil {
+		return opError(OpDelete, name, ErrFailedToDeleteService, err)
+	}
+
+	return nil
+}
+
+func (m scmManager) Query(name string) (Status, error) {
+	service, close, err := m.open(OpQuery, name)
+	if err != nil {
+		return Status{}, err
+	}
+	defer close()
+
+	status, err := service.Query()
+	if err != nil {
+		return Status{}, opError(OpQuery, name, ErrFailedToGetServiceStatus, err)
+	}
+
+	return Status{State: State(status.State), CheckPoint: status.CheckPoint, WaitHint: status.WaitHint}, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"io"
+
+	"github.com/rs/zerolog"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/restart"
+)
+
+// Option configures the WindowsService returned by NewService
+type Option func(*WindowsService)
+
+// WithLogger routes the messages of the management methods to logger instead of the global zerolog logger,
+// zerolog.Nop() silences them
+func WithLogger(logger zerolog.Logger) Option {
+	return func(w *WindowsService) {
+		w.logger = &logger
+	}
+}
+
+// WithOutput writes the service log to out instead of the rotating log file of the config,
+// the children with their own log file keep writing to it
+func WithOutput(out io.Writer) Option {
+	return func(w *WindowsService) {
+		w.output = out
+	}
+}
+
+// WithClock sets the clock timing the restarts, health checks and waits, clock.System by default
+func WithClock(clk clock.Clock) Option {
+	return func(w *WindowsService) {
+		w.clock = clk
+	}
+}
+
+// WithRestartPolicy restarts every child with policy instead of the restart policy of its config, also after a reload
+func WithRestartPolicy(policy restart.Policy) Option {
+	return func(w *WindowsService) {
+		w.restartPolicy = &policy
+	}
+}
+
+// WithConfigError marks the config of the service as unusable, Supervise logs err and stops the service
+// with ExitCodeInvalidConfig without starting the children
+func WithConfigError(err error) Option {
+	return func(w *WindowsService) {
+		w.cfgErr = err
+	}
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/restart"
+)
+
+func TestNewServiceOptions(t *testing.T) {
+	var messages bytes.Buffer
+	output := &lockedBuffer{}
+	clk := clock.NewFake(time.Now())
+	policy, err := restart.NewPolicy(config.RestartPolicy{Mode: "never"})
+	require.NoError(t, err)
+	logPath := filepath.Join(t.TempDir(), "service.log")
+
+	w := NewService(config.WindowsServiceConfig{
+		Name:          "svc",
+		ChildExecPath: "/bin/sh",
+		ChildExecArgs: []string{"-c", "echo serving; exec sleep 30"},
+		LogFilePath:   logPath,
+		RestartPolicy: config.RestartPolicy{Mode: "sometimes"},
+	}, WithLogger(zerolog.New(&messages)), WithOutput(output), WithClock(clk), WithRestartPolicy(policy))
+	w.Manager = NewFakeManager(clk)
+	require.NoError(t, w.cfgErr)
+	require.Equal(t, policy, w.Children[0].RestartPolicy)
+	require.Equal(t, clock.Clock(clk), w.clock)
+
+	require.NoError(t, w.Install(context.Background()))
+	require.Contains(t, messages.String(), "Service svc installed")
+
+	commands := make(chan Command)
+	states, done := supervise(w, commands)
+	waitState(t, states, Running)
+	commands <- CommandStop
+	<-done
+	require.Contains(t, output.String(), "Process started\n")
+	require.NoFileExists(t, logPath)
+}
+
+func TestWithConfigError(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "service.log")
+	w := NewService(config.WindowsServiceConfig{LogFilePath: logPath}, WithConfigError(errors.New("unknown variable ${nope}")))
+	_, done := supervise(w, make(chan Command))
+
+	require.Equal(t, ExitCodeInvalidConfig, <-done)
+	require.Equal(t, "Invalid service config: unknown variable ${nope}\n", readLog(t, logPath))
+}
+
+func TestServiceContext(t *testing.T) {
+	m := NewFakeManager(clock.System{})
+	m.StartDelay = time.Hour
+	w := newTestService(t, m)
+	require.NoError(t, w.Install(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, w.Start(ctx), context.Canceled)
+	require.Equal(t, []Operation{OpInstall}, m.Calls())
+
+	// a deadline of the context shorter than Timeout bounds the wait
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := w.Start(ctx)
+	require.ErrorIs(t, err, ErrStartTimeoutExceeded)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+const configWatchInterval = 2 * time.Second
+
+// restartFields are the fields of a child whose changes are applied by restarting it
+var restartFields = []string{"execPath", "execArgs", "workDir", "env", "envFiles", "inheritEnv", "logFilePath"}
+
+// reconfiguration carries the new settings of a child to its supervisor
+type reconfiguration struct {
+	cfg     *Child
+	changes []string
+}
+
+// installFields are the config fields kept by the service manager, they are applied by Reconfigure
+var installFields = []string{"description", "parentExecPath", "displayName", "startType", "account", "password", "dependencies", "recovery"}
+
+// reload loads and validates the configuration file and applies the changes to the running service,
+// an invalid configuration is rejected and the previous one is kept
+func (w *WindowsService) reload(supervisors []*supervisor) {
+	cfg, err := config.New(w.ConfigPath, w.ConfigOverlays...)
+	if err == nil {
+		err = Validate(cfg)
+	}
+	var nextCfgs []config.Child
+	if err == nil {
+		nextCfgs, err = cfg.ChildConfigs()
+	}
+	nextChildren := make([]*Child, len(nextCfgs))
+	for i, c := range nextCfgs {
+		if err != nil {
+			break
+		}
+		nextChildren[i], err = w.newChild(cfg, c)
+	}
+	if err != nil {
+		w.log.Write([]byte(fmt.Sprintf("Config reload rejected, keeping the previous config: %s\n", err.Error())))
+		return
+	}
+	w.log.setRedact(cfg.Redact)
+	for _, c := range w.Children {
+		if c.logFile != nil {
+			c.logFile.setRedact(cfg.Redact)
+		}
+	}
+
+	changes := config.Changes(w.cfg, cfg)
+	if len(changes) == 0 {
+		w.log.Write([]byte("Config reloaded, nothing changed\n"))
+		return
+	}
+	w.log.Write([]byte(fmt.Sprintf("Config reloaded, changed: %s\n", strings.Join(changes, ", "))))
+
+	var deferred, installed []string
+	for _, change := range changes {
+		switch {
+		case slices.Contains(installFields, change):
+			installed = append(installed, change)
+		case change == "name" || strings.HasSuffix(change, "]") || strings.HasSuffix(change, ".dependsOn"):
+			deferred = append(deferred, change)
+		}
+	}
+	if len(deferred) > 0 {
+		w.log.Write([]byte(fmt.Sprintf("Config changes to %s are applied on the next start of the service\n", strings.Join(deferred, ", "))))
+	}
+	if len(installed) > 0 {
+		w.log.Write([]byte(fmt.Sprintf("Config changes to %s are applied by the reconfigure command\n", strings.Join(installed, ", "))))
+	}
+
+	if slices.ContainsFunc(changes, func(change string) bool { return strings.HasPrefix(change, "logFile") }) {
+		if w.output == nil {
+			w.log.update(newRotatingLog(cfg, serviceLogPath(cfg)))
+		}
+		if err := w.status.setPath(statusPath(cfg)); err != nil {
+			w.log.Write([]byte(fmt.Sprintf("Failed to write status file: %s\n", err.Error())))
+		}
+	}
+
+	prevCfgs, _ := w.cfg.ChildConfigs()
+	for i, prev := range w.Children {
+		j := slices.IndexFunc(nextCfgs, func(c config.Child) bool { return c.Name == prev.Name })
+		if j < 0 {
+			continue
+		}
+		c := nextChildren[j]
+		childChanges := config.ChildChanges(prevCfgs[i], nextCfgs[j])
+		restart := slices.ContainsFunc(childChanges, func(change string) bool { return slices.Contains(restartFields, change) })
+		if !restart && prev.logFile != nil {
+			prev.logFile.update(c.logFile)
+			c.logFile, c.output = prev.logFile, prev.output
+		}
+		w.Children[i] = c
+
+		k := slices.IndexFunc(supervisors, func(s *supervisor) bool { return s.name == prev.Name })
+		if k < 0 {
+			if restart && prev.logFile != nil {
+				prev.logFile.Close()
+			}
+			continue
+		}
+		select {
+		case supervisors[k].reconfigure <- reconfiguration{cfg: c, changes: childChanges}:
+		case <-supervisors[k].done:
+		}
+	}
+	w.cfg = cfg
+}
+
+// configModified reports whether the configuration file or its overlays were modified since the previous call
+func (w *WindowsService) configModified() bool {
+	var modTime time.Time
+	for _, path := range append([]string{w.ConfigPath}, w.ConfigOverlays...) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if modTime.Equal(w.cfgModTime) {
+		return false
+	}
+	w.cfgModTime = modTime
+
+	return true
+}
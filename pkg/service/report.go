@@ -0,0 +1,167 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+// States of a child process in a Report
+const (
+	ChildStarting   = "starting"
+	ChildRunning    = "running"
+	ChildRestarting = "restarting"
+	ChildStopped    = "stopped"
+	ChildFailed     = "failed"
+)
+
+// Report is the runtime status of the service, the running service keeps it in its status file
+type Report struct {
+	// State is reported by the service manager, the status file keeps the state reported by the supervisor
+	State State `json:"state"`
+	// PID is the process id of the supervisor
+	PID       int           `json:"pid,omitempty"`
+	StartedAt *time.Time    `json:"startedAt,omitempty"`
+	UpdatedAt *time.Time    `json:"updatedAt,omitempty"`
+	Children  []ChildReport `json:"children"`
+}
+
+// ChildReport is the runtime status of a child process
+type ChildReport struct {
+	// Name is empty for the single child described by the legacy child settings of the config
+	Name  string `json:"name,omitempty"`
+	State string `json:"state"`
+	PID   int    `json:"pid,omitempty"`
+	// StartedAt is the time of the last start of the child process, UptimeMs is computed when the report is read
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	UptimeMs  int64      `json:"uptimeMs,omitempty"`
+	// Restarts counts the restarts of the child process since the service started
+	Restarts int            `json:"restarts"`
+	LastExit *ExitReport    `json:"lastExit,omitempty"`
+	Backoff  *BackoffReport `json:"backoff,omitempty"`
+}
+
+// ExitReport describes the last exit of a child process
+type ExitReport struct {
+	Code  int       `json:"code"`
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// BackoffReport describes the pending restart of a child process
+type BackoffReport struct {
+	// Attempt is the number of consecutive restart attempts, 0 for a restart after a clean exit
+	Attempt   int       `json:"attempt"`
+	DelayMs   int64     `json:"delayMs"`
+	RestartAt time.Time `json:"restartAt"`
+}
+
+// statusPath returns the path of the status file, next to the service log with the .status.json extension
+func statusPath(cfg config.WindowsServiceConfig) string {
+	logPath := serviceLogPath(cfg)
+
+	return strings.TrimSuffix(logPath, filepath.Ext(logPath)) + ".status.json"
+}
+
+// ReadReport reads the status file of the service described by cfg
+func ReadReport(cfg config.WindowsServiceConfig) (Report, error) {
+	var report Report
+	data, err := os.ReadFile(statusPath(cfg))
+	if err != nil {
+		return report, err
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, errors.Wrapf(err, "invalid status file %s", statusPath(cfg))
+	}
+
+	return report, nil
+}
+
+// statusTracker keeps the Report of the running service and writes it to the status file on every change
+type statusTracker struct {
+	mu     sync.Mutex
+	path   string
+	clock  clock.Clock
+	report Report
+}
+
+func newStatusTracker(path string, clk clock.Clock, children []*Child) *statusTracker {
+	now := clk.Now()
+	t := &statusTracker{
+		path:  path,
+		clock: clk,
+		report: Report{
+			State:     StartPending,
+			PID:       os.Getpid(),
+			StartedAt: &now,
+		},
+	}
+	for _, c := range children {
+		t.report.Children = append(t.report.Children, ChildReport{Name: c.Name, State: ChildStopped})
+	}
+
+	return t
+}
+
+// update applies change to the report and writes the status file
+func (t *statusTracker) update(change func(r *Report)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	change(&t.report)
+	now := t.clock.Now()
+	t.report.UpdatedAt = &now
+
+	return t.write()
+}
+
+// child applies change to the report of the child name and writes the status file
+func (t *statusTracker) child(name string, change func(c *ChildReport)) error {
+	return t.update(func(r *Report) {
+		for i := range r.Children {
+			if r.Children[i].Name == name {
+				change(&r.Children[i])
+				return
+			}
+		}
+		c := ChildReport{Name: name}
+		change(&c)
+		r.Children = append(r.Children, c)
+	})
+}
+
+// setPath moves the status file to path
+func (t *statusTracker) setPath(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if path == t.path {
+		return nil
+	}
+	os.Remove(t.path)
+	t.path = path
+
+	return t.write()
+}
+
+// write writes the status file through a temporary file, so readers never see a partial report
+func (t *statusTracker) write() error {
+	data, err := json.MarshalIndent(t.report, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, t.path)
+}
@@ -0,0 +1,97 @@
+//go:build !windows
+
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+func TestReportRestarts(t *testing.T) {
+	cfg := config.WindowsServiceConfig{
+		ChildExecPath: "/bin/sh",
+		ChildExecArgs: []string{"-c", "exit 3"},
+		LogFilePath:   filepath.Join(t.TempDir(), "service.log"),
+		RestartPolicy: config.RestartPolicy{InitialDelayMs: 10, MaxAttempts: 2},
+	}
+	w := New(cfg)
+	_, done := supervise(w, make(chan Command))
+	select {
+	case exitCode := <-done:
+		require.Equal(t, ExitCodeChildFailed, exitCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not stop")
+	}
+
+	report, err := ReadReport(cfg)
+	require.NoError(t, err)
+	require.Equal(t, Stopped, report.State)
+	require.Equal(t, os.Getpid(), report.PID)
+	require.Len(t, report.Children, 1)
+	c := report.Children[0]
+	require.Equal(t, ChildFailed, c.State)
+	require.Zero(t, c.PID)
+	require.Equal(t, 2, c.Restarts)
+	require.Equal(t, 3, c.LastExit.Code)
+	require.Equal(t, "exit status 3", c.LastExit.Error)
+	require.Nil(t, c.Backoff)
+}
+
+func TestServiceStatus(t *testing.T) {
+	ctx := context.Background()
+	m := NewFakeManager(clock.System{})
+	w := newTestService(t, m)
+	w.Children[0].ExecArgs = []string{"-c", "exec sleep 30"}
+	_, err := w.Status(ctx)
+	require.ErrorIs(t, err, ErrServiceNotExist)
+	require.NoError(t, w.Install(ctx))
+
+	report, err := w.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Stopped, report.State)
+	require.Empty(t, report.Children)
+
+	commands := make(chan Command)
+	states, done := supervise(w, commands)
+	waitState(t, states, Running)
+	m.SetState("svc", Running)
+
+	report, err = w.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Running, report.State)
+	require.Equal(t, os.Getpid(), report.PID)
+	require.Equal(t, ChildRunning, report.Children[0].State)
+	require.NotZero(t, report.Children[0].PID)
+	require.GreaterOrEqual(t, report.Children[0].UptimeMs, int64(0))
+
+	commands <- CommandStop
+	require.Equal(t, uint32(0), <-done)
+	m.SetState("svc", Stopped)
+
+	report, err = w.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Stopped, report.State)
+	require.Zero(t, report.PID)
+	require.Equal(t, ChildStopped, report.Children[0].State)
+	require.NotNil(t, report.Children[0].StartedAt)
+}
+
+func TestStateText(t *testing.T) {
+	for state := Stopped; state <= Paused; state++ {
+		text, err := state.MarshalText()
+		require.NoError(t, err)
+		var parsed State
+		require.NoError(t, parsed.UnmarshalText(text))
+		require.Equal(t, state, parsed)
+	}
+	text, _ := StartPending.MarshalText()
+	require.Equal(t, "start_pending", string(text))
+}
@@ -2,99 +2,181 @@ package service
 
 import (
 	"fmt"
-	"os/exec"
+	"io"
 	"time"
 
-	"github.com/natefinch/lumberjack"
-	"github.com/nixpare/process"
-	"golang.org/x/sys/windows/svc"
+	"github.com/rs/zerolog"
+
+	"github.com/edwardezs/win-svc/pkg/clock"
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/restart"
 )
 
-const changeStateTimeout = 10 * time.Second
+// Service-specific exit codes reported to the SCM when the service stops on its own
+const (
+	// ExitCodeCrashLoop is reported when a critical child process is caught in a crash loop
+	ExitCodeCrashLoop uint32 = 1
+	// ExitCodeChildFailed is reported when a critical child process failed and may not be restarted
+	ExitCodeChildFailed uint32 = 2
+	// ExitCodeNotReady is reported when a critical child process did not become ready on start
+	ExitCodeNotReady uint32 = 3
+	// ExitCodeInvalidConfig is reported when the service config can not be parsed
+	ExitCodeInvalidConfig uint32 = 4
+)
 
 type WindowsService struct {
 	Name           string
 	Description    string
 	ParentExecPath string
-	ChildExecPath  string
-	ChildExecArgs  []string
-	cmd            *exec.Cmd
-	log            *lumberjack.Logger
+	// Manager installs and controls the service, the platform service manager by default
+	Manager Manager
+	// ConfigPath is the configuration file reloaded on ReloadControlCode or, with watchConfig, once it changes
+	ConfigPath string
+	// ConfigOverlays are applied in order to the configuration file on reload
+	ConfigOverlays []string
+	// Timeout bounds how long Start, Stop, Restart and Delete wait for the service to reach the requested state,
+	// DefaultTimeout when zero
+	Timeout time.Duration
+	// NoWait makes Start return once the service manager accepted the request, Restart once the service is started
+	// and Stop and Delete once the stop request is sent
+	NoWait bool
+	// Children are started in order and stopped in reverse order
+	Children   []*Child
+	cfg        config.WindowsServiceConfig
+	cfgModTime time.Time
+	log        *rotatingLog
+	// output replaces the log file of the service log, set by WithOutput
+	output io.Writer
+	// logger receives the messages of the management methods, set by WithLogger
+	logger *zerolog.Logger
+	// restartPolicy replaces the restart policy of every child, set by WithRestartPolicy
+	restartPolicy *restart.Policy
+	// console mirrors the service log and the output of the children when the service runs in the foreground
+	console io.Writer
+	clock   clock.Clock
+	cfgErr  error
+	// status is the runtime status of the running service, kept in the status file
+	status *statusTracker
 }
 
-func (w *WindowsService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
-	changes <- svc.Status{State: svc.StartPending}
-	defer w.log.Close()
+// Supervise starts the children and supervises them until CommandStop is received or a critical child fails,
+// reporting every status change of the service. The returned exitCode is the service-specific exit code.
+func (w *WindowsService) Supervise(commands <-chan Command, report func(Status)) (exitCode uint32) {
+	w.status = newStatusTracker(statusPath(w.cfg), w.clock, w.Children)
+	report = w.trackState(report)
+	report(Status{State: StartPending})
+	defer w.closeLogs()
+
+	if w.cfgErr != nil {
+		w.log.Write([]byte(fmt.Sprintf("Invalid service config: %s\n", w.cfgErr.Error())))
+		return ExitCodeInvalidConfig
+	}
+
+	var checkpoint uint32
+	progress := func(waitHint uint32) {
+		checkpoint++
+		report(Status{State: StartPending, CheckPoint: checkpoint, WaitHint: waitHint})
+	}
 
-	processExited := make(chan error)
-	if err := w.startProcess(processExited); err != nil {
-		w.log.Write([]byte(fmt.Sprintf("Failed to start process: %s\n", err.Error())))
-		return
+	finished := make(chan *supervisor, len(w.Children))
+	supervisors := make([]*supervisor, 0, len(w.Children))
+	for _, c := range w.Children {
+		s := w.newSupervisor(c)
+		if exitCode, err := s.startReady(progress); err != nil {
+			s.logf("Failed to start process: %s\n", err.Error())
+			s.track(func(c *ChildReport) {
+				c.State = ChildFailed
+			})
+			if !c.Critical {
+				w.log.Write([]byte(fmt.Sprintf("Process %s is not running, service is degraded\n", c.Name)))
+				continue
+			}
+			stopAll(supervisors)
+			return exitCode
+		}
+		s.ready("Process started\n")
+		go s.run(finished)
+		supervisors = append(supervisors, s)
 	}
 
-	w.log.Write([]byte("Process started\n"))
-	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	report(Status{State: Running})
+
+	var watch <-chan time.Time
+	if w.ConfigPath != "" {
+		w.configModified()
+	}
 
+	running := len(supervisors)
 loop:
-	for {
+	for running > 0 {
+		if watch == nil && w.ConfigPath != "" && w.cfg.WatchConfig {
+			watch = w.clock.After(configWatchInterval)
+		}
 		select {
-		case c := <-r:
-			switch c.Cmd {
-			case svc.Stop, svc.Shutdown:
-				changes <- svc.Status{State: svc.StopPending}
-				if err := w.stopProcess(processExited); err != nil {
-					w.log.Write([]byte(fmt.Sprintf("Failed to stop process: %s\n", err.Error())))
-				}
-				w.log.Write([]byte("Process stopped\n"))
+		case c := <-commands:
+			switch c {
+			case CommandStop:
+				report(Status{State: StopPending})
+				stopAll(supervisors)
 				break loop
-			default:
-				w.log.Write([]byte(fmt.Sprintf("Unexpected control request #%d\n", c)))
-			}
-		case err := <-processExited:
-			if err == nil {
-				w.log.Write([]byte("Process exited with no error\n"))
-				break
-			}
-			w.log.Write([]byte(fmt.Sprintf("Process exited with error: %s, attempting restart\n", err.Error())))
-			timeout := time.Now().Add(changeStateTimeout)
-			for {
-				if timeout.Before(time.Now()) {
-					w.log.Write([]byte("Timeout waiting for process to restart exceeded\n"))
-					break loop
-				}
-				if err := w.startProcess(processExited); err != nil {
-					w.log.Write([]byte("Failed to start process, retrying\n"))
-				} else {
-					w.log.Write([]byte("Process restarted\n"))
+			case CommandReload:
+				if w.ConfigPath == "" {
+					w.log.Write([]byte("Config reload requested, but the service has no configuration file\n"))
 					break
 				}
+				w.configModified()
+				w.reload(supervisors)
+			}
+		case s := <-finished:
+			running--
+			if s.cfg.Critical {
+				exitCode = s.exitCode
+				report(Status{State: StopPending})
+				stopAll(supervisors)
+				break loop
+			}
+			w.log.Write([]byte(fmt.Sprintf("Process %s is not running, service is degraded\n", s.cfg.Name)))
+		case <-watch:
+			watch = nil
+			if w.configModified() {
+				w.reload(supervisors)
 			}
 		}
 	}
 
-	changes <- svc.Status{State: svc.Stopped}
-	return
+	report(Status{State: Stopped})
+	return exitCode
 }
 
-func (w *WindowsService) startProcess(processExited chan error) error {
-	w.cmd = exec.Command(w.ChildExecPath, w.ChildExecArgs...)
-	w.cmd.Stdout = w.log
-	w.cmd.Stderr = w.log
-	if err := w.cmd.Start(); err != nil {
-		return ErrFailedToStartService
+// trackState returns report recording every reported state in the status file
+func (w *WindowsService) trackState(report func(Status)) func(Status) {
+	return func(s Status) {
+		w.track(func(r *Report) {
+			r.State = s.State
+		})
+		report(s)
 	}
+}
 
-	go func() {
-		processExited <- w.cmd.Wait()
-	}()
-
-	return nil
+// track records a change of the runtime status of the service in the status file
+func (w *WindowsService) track(change func(r *Report)) {
+	if err := w.status.update(change); err != nil {
+		w.log.Write([]byte(fmt.Sprintf("Failed to write status file: %s\n", err.Error())))
+	}
 }
 
-func (w *WindowsService) stopProcess(processExited chan error) error {
-	if err := process.StopProcess(w.cmd.Process.Pid); err != nil {
-		return ErrFailedToStopService
+// stopAll stops the supervisors in reverse start order
+func stopAll(supervisors []*supervisor) {
+	for i := len(supervisors) - 1; i >= 0; i-- {
+		supervisors[i].requestStop()
 	}
+}
 
-	return <-processExited
+func (w *WindowsService) closeLogs() {
+	for _, c := range w.Children {
+		if c.logFile != nil {
+			c.logFile.Close()
+		}
+	}
+	w.log.Close()
 }
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package service
+
+import "os"
+
+// IsService reports whether the process was started by the Windows service control manager, never on this platform
+func IsService() (bool, error) {
+	return false, nil
+}
+
+// installArgs returns the arguments the service manager runs the service binary with,
+// the service runs the supervisor in the foreground with the configuration it was installed from
+func (w *WindowsService) installArgs() []string {
+	args := []string{"-config", w.ConfigPath}
+	for _, overlay := range w.ConfigOverlays {
+		args = append(args, "-overlay", overlay)
+	}
+
+	return append(args, "run")
+}
+
+// Run supervises the children in the foreground, the process exits with the service-specific exit code
+func (w *WindowsService) Run() {
+	if exitCode := w.RunForeground(os.Stdout); exitCode != 0 {
+		os.Exit(int(exitCode))
+	}
+}
@@ -0,0 +1,296 @@
+//go:build !windows
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+)
+
+// supervise runs Supervise in the background, it returns the channel of reported states and of the exit code
+func supervise(w *WindowsService, commands <-chan Command) (<-chan State, <-chan uint32) {
+	states := make(chan State, 100)
+	done := make(chan uint32, 1)
+	go func() {
+		done <- w.Supervise(commands, func(s Status) {
+			states <- s.State
+		})
+	}()
+
+	return states, done
+}
+
+func waitState(t *testing.T, states <-chan State, want State) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case s := <-states:
+			if s == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for state %s", want)
+		}
+	}
+}
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func TestSuperviseStop(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "service.log")
+	w := New(config.WindowsServiceConfig{
+		ChildExecPath: "/bin/sh",
+		ChildExecArgs: []string{"-c", "echo serving; exec sleep 30"},
+		LogFilePath:   logPath,
+	})
+	commands := make(chan Command)
+	states, done := supervise(w, commands)
+
+	waitState(t, states, Running)
+	commands <- CommandStop
+	require.Equal(t, uint32(0), <-done)
+	waitState(t, states, Stopped)
+
+	log := readLog(t, logPath)
+	require.Contains(t, log, "Process started\n")
+	require.Contains(t, log, "Process stopped\n")
+}
+
+func TestSuperviseInvalidConfig(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "service.log")
+	w := New(config.WindowsServiceConfig{
+		ChildExecPath: "/bin/sh",
+		LogFilePath:   logPath,
+		RestartPolicy: config.RestartPolicy{Mode: "sometimes"},
+	})
+	_, done := supervise(w, make(chan Command))
+
+	require.Equal(t, ExitCodeInvalidConfig, <-done)
+	require.Contains(t, readLog(t, logPath), "Invalid service config: ")
+}
+
+func TestSuperviseCriticalChildFails(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "service.log")
+	w := New(config.WindowsServiceConfig{
+		ChildExecPath: "/bin/sh",
+		ChildExecArgs: []string{"-c", "sleep 0.1; exit 3"},
+		LogFilePath:   logPath,
+		RestartPolicy: config.RestartPolicy{Mode: "never"},
+	})
+	_, done := supervise(w, make(chan Command))
+
+	select {
+	case exitCode := <-done:
+		require.Equal(t, ExitCodeChildFailed, exitCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not stop")
+	}
+	require.Contains(t, readLog(t, logPath), "Process exited with error: exit status 3, restart is not allowed, stopping\n")
+}
+
+func TestSuperviseChildren(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "service.log")
+	w := New(config.WindowsServiceConfig{
+		ParentExecPath: filepath.Join(dir, "service"),
+		LogFilePath:    logPath,
+		Children: []config.Child{
+			{
+				Name:      "api",
+				ExecPath:  "/bin/sh",
+				ExecArgs:  []string{"-c", "exec sleep 30"},
+				DependsOn: []string{"db"},
+				Critical:  true,
+			},
+			{
+				Name:          "worker",
+				ExecPath:      "/bin/sh",
+				ExecArgs:      []string{"-c", "echo working; exit 1"},
+				LogFilePath:   filepath.Join(dir, "worker.log"),
+				RestartPolicy: config.RestartPolicy{Mode: "never"},
+				DependsOn:     []string{"api"},
+			},
+			{
+				Name:     "db",
+				ExecPath: "/bin/sh",
+				ExecArgs: []string{"-c", "exec sleep 30"},
+				Critical: true,
+			},
+		},
+	})
+	commands := make(chan Command)
+	states, done := supervise(w, commands)
+
+	waitState(t, states, Running)
+	require.Eventually(t, func() bool {
+		return strings.Contains(readLog(t, logPath), "Process worker is not running, service is degraded\n")
+	}, 5*time.Second, 10*time.Millisecond)
+	commands <- CommandStop
+	require.Equal(t, uint32(0), <-done)
+
+	log := readLog(t, logPath)
+	require.Less(t, strings.Index(log, "[db] Process started"), strings.Index(log, "[api] Process started"))
+	require.Less(t, strings.Index(log, "[api] Process started"), strings.Index(log, "[worker] Process started"))
+	require.Less(t, strings.Index(log, "[api] Process stopped"), strings.Index(log, "[db] Process stopped"))
+	require.Contains(t, log, "[worker] Process exited with error: exit status 1, restart is not allowed, stopping\n")
+	require.NotContains(t, log, "working")
+	require.Equal(t, "working\n", readLog(t, filepath.Join(dir, "worker.log")))
+}
+
+func TestSuperviseChildOutputLines(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "service.log")
+	w := New(config.WindowsServiceConfig{
+		ParentExecPath: filepath.Join(dir, "service"),
+		LogFilePath:    logPath,
+		Children: []config.Child{{
+			Name:     "api",
+			ExecPath: "/bin/sh",
+			// several lines in one write, the last one split across writes
+			ExecArgs: []string{"-c", `sleep 0.2; printf 'one\ntwo\nthr'; sleep 0.1; printf 'ee\n'; exec sleep 30`},
+			Critical: true,
+		}},
+	})
+	commands := make(chan Command)
+	states, done := supervise(w, commands)
+	waitState(t, states, Running)
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(readLog(t, logPath), "ee\n")
+	}, 5*time.Second, 10*time.Millisecond)
+	commands <- CommandStop
+	require.Equal(t, uint32(0), <-done)
+	require.Contains(t, readLog(t, logPath), "[api] one\n[api] two\n[api] three\n")
+}
+
+func TestSuperviseReload(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "service.config.json")
+	writeConfig := func(args string) {
+		require.NoError(t, os.WriteFile(cfgPath, []byte(`{
+			"name": "svc",
+			"description": "Test service",
+			"parentExecPath": "/bin/sh",
+			"childExecPath": "/bin/sh",
+			"childExecArgs": ["-c", "echo `+args+`; exec sleep 30"],
+			"logFilePath": "${configDir}/service.log"
+		}`), 0o644))
+	}
+	writeConfig("first")
+	cfg, err := config.New(cfgPath)
+	require.NoError(t, err)
+	w := New(cfg)
+	w.ConfigPath = cfgPath
+	logPath := filepath.Join(dir, "service.log")
+	commands := make(chan Command)
+	states, done := supervise(w, commands)
+	waitState(t, states, Running)
+
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{"name": `), 0o644))
+	commands <- CommandReload
+	require.Eventually(t, func() bool {
+		return strings.Contains(readLog(t, logPath), "Config reload rejected, keeping the previous config")
+	}, 5*time.Second, 10*time.Millisecond)
+	writeConfig("second")
+	commands <- CommandReload
+	require.Eventually(t, func() bool {
+		return strings.Contains(readLog(t, logPath), "second\n")
+	}, 5*time.Second, 10*time.Millisecond)
+	commands <- CommandStop
+	require.Equal(t, uint32(0), <-done)
+
+	log := readLog(t, logPath)
+	require.Contains(t, log, "Config reloaded, changed: childExecArgs\n")
+	require.Contains(t, log, "Restarting process to apply config changes\n")
+	require.Contains(t, log, "Process restarted\n")
+}
+
+func TestSuperviseReloadRedactsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "service.config.json")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{
+		"name": "svc",
+		"description": "Test service",
+		"parentExecPath": "/bin/sh",
+		"childExecPath": "/bin/sh",
+		"childExecArgs": ["-c", "echo token $TOKEN; exec sleep 30"],
+		"childEnv": {"TOKEN": "${secret:token}"},
+		"logFilePath": "${configDir}/service.log",
+		"secrets": {"keyFile": "svc.key"}
+	}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "svc.key"), []byte("key material\n"), 0o600))
+	setToken := func(token string) {
+		store, err := config.OpenSecrets(cfgPath)
+		require.NoError(t, err)
+		require.NoError(t, store.Set("token", token))
+		require.NoError(t, store.Save())
+	}
+	setToken("first-t0k3n")
+	cfg, err := config.New(cfgPath)
+	require.NoError(t, err)
+	w := New(cfg)
+	w.ConfigPath = cfgPath
+	logPath := filepath.Join(dir, "service.log")
+	commands := make(chan Command)
+	states, done := supervise(w, commands)
+	waitState(t, states, Running)
+
+	setToken("second-t0k3n")
+	commands <- CommandReload
+	require.Eventually(t, func() bool {
+		return strings.Count(readLog(t, logPath), "token ****\n") == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	commands <- CommandStop
+	require.Equal(t, uint32(0), <-done)
+
+	log := readLog(t, logPath)
+	require.NotContains(t, log, "first-t0k3n")
+	require.NotContains(t, log, "second-t0k3n")
+}
+
+func TestSuperviseRestartWaitsForReadiness(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "service.log")
+	ready := filepath.Join(dir, "ready")
+	w := New(config.WindowsServiceConfig{
+		ChildExecPath: "/bin/sh",
+		ChildExecArgs: []string{"-c", "if [ -f started ]; then sleep 0.3; echo now ready; touch ready; exec sleep 30; fi; " +
+			"touch started ready; sleep 0.2; exit 1"},
+		ChildWorkDir:  dir,
+		LogFilePath:   logPath,
+		RestartPolicy: config.RestartPolicy{InitialDelayMs: 10},
+		Readiness:     config.Readiness{Type: "file", Path: ready, IntervalMs: 20},
+		Hooks: config.Hooks{
+			PreStart: []config.Hook{{Command: []string{"rm", "-f", ready}}},
+			// post-start hooks run once the child is ready, after a restart too
+			PostStart: []config.Hook{{Command: []string{"test", "-f", ready}}},
+		},
+	})
+	commands := make(chan Command)
+	_, done := supervise(w, commands)
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(readLog(t, logPath), "Process restarted\n")
+	}, 5*time.Second, 10*time.Millisecond)
+	commands <- CommandStop
+	require.Equal(t, uint32(0), <-done)
+
+	log := readLog(t, logPath)
+	require.Contains(t, log, "now ready\n")
+	require.Less(t, strings.Index(log, "now ready\n"), strings.Index(log, "Process restarted\n"))
+	require.NotContains(t, log, "Failed to start process")
+}
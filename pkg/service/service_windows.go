@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// ReloadControlCode is the custom control code that makes a running service reload its configuration file
+const ReloadControlCode = svc.Cmd(128)
+
+// IsService reports whether the process was started by the Windows service control manager
+func IsService() (bool, error) {
+	return svc.IsWindowsService()
+}
+
+// installArgs returns the arguments the service manager runs the service binary with,
+// the service loads the configuration it was installed from
+func (w *WindowsService) installArgs() []string {
+	args := []string{"-config", w.ConfigPath}
+	for _, overlay := range w.ConfigOverlays {
+		args = append(args, "-overlay", overlay)
+	}
+
+	return args
+}
+
+func (w *WindowsService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
+	commands := make(chan Command)
+	done := make(chan uint32)
+	go func() {
+		done <- w.Supervise(commands, func(s Status) {
+			status := svc.Status{State: svc.State(s.State), CheckPoint: s.CheckPoint, WaitHint: s.WaitHint}
+			if s.State == Running {
+				status.Accepts = svc.AcceptStop | svc.AcceptShutdown
+			}
+			changes <- status
+		})
+	}()
+
+	for {
+		var command Command
+		select {
+		case exitCode := <-done:
+			return exitCode != 0, exitCode
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				changes <- c.CurrentStatus
+				continue
+			case svc.Stop, svc.Shutdown:
+				command = CommandStop
+			case ReloadControlCode:
+				command = CommandReload
+			default:
+				w.log.Write([]byte(fmt.Sprintf("Unexpected control request #%d\n", c)))
+				continue
+			}
+		}
+
+		select {
+		case commands <- command:
+		case exitCode := <-done:
+			return exitCode != 0, exitCode
+		}
+	}
+}
+
+func (w *WindowsService) Run() {
+	if err := svc.Run(w.Name, w); err != nil {
+		w.log.Write([]byte(fmt.Sprintf("Failed to start service: %s\n", err.Error())))
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// State is the state of a service, the values match the states of the Windows service control manager
+type State uint32
+
+const (
+	Stopped         State = 1
+	StartPending    State = 2
+	StopPending     State = 3
+	Running         State = 4
+	ContinuePending State = 5
+	PausePending    State = 6
+	Paused          State = 7
+)
+
+func (s State) String() string {
+	switch s {
+	case Stopped:
+		return "stopped"
+	case StartPending:
+		return "start pending"
+	case StopPending:
+		return "stop pending"
+	case Running:
+		return "running"
+	case ContinuePending:
+		return "continue pending"
+	case PausePending:
+		return "pause pending"
+	case Paused:
+		return "paused"
+	default:
+		return fmt.Sprintf("unknown state %d", uint32(s))
+	}
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(strings.ReplaceAll(s.String(), " ", "_")), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	for state := Stopped; state <= Paused; state++ {
+		if string(text) == strings.ReplaceAll(state.String(), " ", "_") {
+			*s = state
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown state %q", text)
+}
+
+// Status is the status of a service as reported by its supervisor or a Manager
+type Status struct {
+	State State
+	// CheckPoint is increased while a pending state makes progress, WaitHint is the time in milliseconds
+	// the pending state is expected to take until the next check point
+	CheckPoint uint32
+	WaitHint   uint32
+}
+
+// Command is a control request sent to a running supervisor
+type Command int
+
+const (
+	// CommandStop stops the children and the supervisor
+	CommandStop Command = iota
+	// CommandReload reloads the configuration file
+	CommandReload
+)
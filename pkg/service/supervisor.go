@@ -0,0 +1,409 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/child"
+	"github.com/edwardezs/win-svc/pkg/health"
+	"github.com/edwardezs/win-svc/pkg/hook"
+	"github.com/edwardezs/win-svc/pkg/restart"
+)
+
+// Child is a child process supervised by the service
+type Child struct {
+	// Name is empty for the single child described by the legacy child settings of the config
+	Name          string
+	ExecPath      string
+	ExecArgs      []string
+	WorkDir       string
+	Env           child.Env
+	RestartPolicy restart.Policy
+	CrashLoop     restart.CrashLoopPolicy
+	StopSequence  child.StopSequence
+	HealthChecks  []health.Check
+	Readiness     *health.Gate
+	Hooks         hook.Hooks
+	DependsOn     []string
+	Critical      bool
+	// output receives the output of the child process, events the supervision events prefixed with Name
+	output io.Writer
+	events io.Writer
+	// logFile is the own log file of the child, if it has one
+	logFile *rotatingLog
+}
+
+// supervisor runs the supervision loop of a single child process
+type supervisor struct {
+	w             *WindowsService
+	name          string
+	cfg           *Child
+	proc          *child.Process
+	scheduler     *restart.Scheduler
+	breaker       *restart.Breaker
+	monitor       *health.Monitor
+	processExited chan error
+	notified      <-chan struct{}
+	unhealthy     chan error
+	restartTimer  <-chan time.Time
+	stopProbes    context.CancelFunc
+	running       bool
+	reconfigure   chan reconfiguration
+	stopRequested chan struct{}
+	stopOnce      sync.Once
+	done          chan struct{}
+	exitCode      uint32
+}
+
+func (w *WindowsService) newSupervisor(c *Child) *supervisor {
+	return &supervisor{
+		w:             w,
+		name:          c.Name,
+		cfg:           c,
+		scheduler:     restart.NewScheduler(c.RestartPolicy, w.clock),
+		breaker:       restart.NewBreaker(c.CrashLoop, w.clock),
+		monitor:       health.NewMonitor(c.HealthChecks, w.clock, c.events),
+		stopProbes:    func() {},
+		reconfigure:   make(chan reconfiguration),
+		stopRequested: make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+func (s *supervisor) logf(format string, args ...any) {
+	s.cfg.events.Write([]byte(fmt.Sprintf(format, args...)))
+}
+
+// track records a change of the runtime status of the child in the status file
+func (s *supervisor) track(change func(c *ChildReport)) {
+	if err := s.w.status.child(s.name, change); err != nil {
+		s.logf("Failed to write status file: %s\n", err.Error())
+	}
+}
+
+// ready records the started child process as running and logs event
+func (s *supervisor) ready(event string) {
+	s.track(func(c *ChildReport) {
+		c.State = ChildRunning
+	})
+	s.logf(event)
+}
+
+// startReady starts the child process, waits until it is ready and runs the post-start hooks, on the first start
+// and on every restart, exitCode is the service-specific exit code to report if it fails
+func (s *supervisor) startReady(report func(waitHint uint32)) (exitCode uint32, err error) {
+	if err := s.start(); err != nil {
+		return ExitCodeChildFailed, err
+	}
+	if err := s.waitReady(report); err != nil {
+		return ExitCodeNotReady, err
+	}
+	if err := s.postStart(); err != nil {
+		return ExitCodeChildFailed, err
+	}
+
+	return 0, nil
+}
+
+// run supervises the started child process until it is stopped by requestStop or may not be restarted,
+// in the latter case the supervisor is sent to finished with exitCode set
+func (s *supervisor) run(finished chan<- *supervisor) {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.stopRequested:
+			s.stop()
+			s.track(func(c *ChildReport) {
+				c.State, c.PID, c.Backoff = ChildStopped, 0, nil
+			})
+			s.logf("Process stopped\n")
+			s.cfg.Hooks.Run(hook.StagePostStop, s.cfg.events)
+			return
+		case err := <-s.processExited:
+			if exitCode, done := s.exited(err); done {
+				s.exitCode = exitCode
+				finished <- s
+				return
+			}
+		case err := <-s.unhealthy:
+			if !s.running {
+				break
+			}
+			s.logf("Process is unhealthy: %s, stopping it\n", err.Error())
+			s.stop()
+			if exitCode, done := s.exited(err); done {
+				s.exitCode = exitCode
+				finished <- s
+				return
+			}
+		case <-s.restartTimer:
+			s.restartTimer = nil
+			if _, err := s.startReady(func(uint32) {}); err != nil {
+				if exitCode, done := s.retry(fmt.Sprintf("Failed to start process: %s", err.Error())); done {
+					s.exitCode = exitCode
+					finished <- s
+					return
+				}
+				break
+			}
+			s.ready("Process restarted\n")
+		case r := <-s.reconfigure:
+			if exitCode, done := s.apply(r); done {
+				s.exitCode = exitCode
+				finished <- s
+				return
+			}
+		}
+	}
+}
+
+// apply replaces the settings of the child, restarting it if settings of the process itself changed,
+// done is set when the supervision must end with the service-specific exitCode
+func (s *supervisor) apply(r reconfiguration) (exitCode uint32, done bool) {
+	prev := s.cfg
+	restart := slices.ContainsFunc(r.changes, func(change string) bool { return slices.Contains(restartFields, change) })
+	if restart && s.running {
+		s.logf("Restarting process to apply config changes\n")
+		s.stop()
+		s.cfg.Hooks.Run(hook.StagePostStop, s.cfg.events)
+	}
+
+	s.cfg = r.cfg
+	s.scheduler.SetPolicy(r.cfg.RestartPolicy)
+	s.breaker.SetPolicy(r.cfg.CrashLoop)
+	if slices.Contains(r.changes, "healthChecks") {
+		s.stopProbes()
+		s.monitor = health.NewMonitor(r.cfg.HealthChecks, s.w.clock, r.cfg.events)
+		if s.running {
+			s.startProbes()
+		}
+	}
+	if !restart {
+		return 0, false
+	}
+	if prev.logFile != nil {
+		prev.logFile.Close()
+	}
+	if s.restartTimer != nil {
+		// the pending restart starts the process with the new settings
+		return 0, false
+	}
+
+	if _, err := s.startReady(func(uint32) {}); err != nil {
+		return s.retry(fmt.Sprintf("Failed to start process: %s", err.Error()))
+	}
+	s.ready("Process restarted\n")
+
+	return 0, false
+}
+
+// requestStop stops the supervision loop and waits for it to finish
+func (s *supervisor) requestStop() {
+	s.stopOnce.Do(func() {
+		close(s.stopRequested)
+	})
+	<-s.done
+}
+
+// start runs the pre-start hooks and starts the child process and its health checks
+func (s *supervisor) start() error {
+	if err := s.cfg.Hooks.Run(hook.StagePreStart, s.cfg.events); err != nil {
+		return err
+	}
+
+	stdout := s.cfg.output
+	if s.cfg.Readiness != nil && s.cfg.Readiness.Message != "" {
+		stdout, s.notified = health.NewNotifyWriter(s.cfg.output, s.cfg.Readiness.Message)
+	}
+	if err := s.startProcess(stdout); err != nil {
+		return err
+	}
+	now := s.w.clock.Now()
+	s.track(func(c *ChildReport) {
+		if c.StartedAt != nil {
+			c.Restarts++
+		}
+		c.State, c.PID, c.StartedAt, c.Backoff = ChildStarting, s.proc.Pid(), &now, nil
+	})
+	s.running = true
+	s.scheduler.Started()
+	s.breaker.Started()
+
+	s.startProbes()
+
+	return nil
+}
+
+// startProbes starts the health checks of the running child process
+func (s *supervisor) startProbes() {
+	// a fresh channel keeps a late report from the previous process away from this one
+	s.unhealthy = make(chan error)
+	var ctx context.Context
+	ctx, s.stopProbes = context.WithCancel(context.Background())
+	go s.monitor.Run(ctx, s.unhealthy)
+}
+
+// waitReady waits until the child process passes its readiness check, calling report on every attempt,
+// the child process is stopped if it does not
+func (s *supervisor) waitReady(report func(waitHint uint32)) error {
+	gate := s.cfg.Readiness
+	if gate == nil {
+		return nil
+	}
+
+	waitHint := uint32(2 * (gate.Interval + gate.Timeout) / time.Millisecond)
+	err := gate.Wait(s.w.clock, s.notified, s.processExited, func(uint32) {
+		report(waitHint)
+	})
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, health.ErrExitedBeforeReady) {
+		s.running = false
+		s.stopProbes()
+		s.proc.Cleanup()
+	} else {
+		s.stop()
+	}
+
+	return err
+}
+
+// postStart runs the post-start hooks, the child process is stopped if they fail
+func (s *supervisor) postStart() error {
+	if err := s.cfg.Hooks.Run(hook.StagePostStart, s.cfg.events); err != nil {
+		s.stop()
+		return err
+	}
+
+	return nil
+}
+
+// stop stops the health checks and, after the pre-stop hooks, the child process if it is running
+func (s *supervisor) stop() {
+	s.stopProbes()
+	if !s.running {
+		return
+	}
+	s.cfg.Hooks.Run(hook.StagePreStop, s.cfg.events)
+	if err := s.stopProcess(); err != nil {
+		s.logf("Failed to stop process: %s\n", err.Error())
+	}
+	s.running = false
+}
+
+// exited handles the exit of the child process,
+// done is set when the supervision must end with the service-specific exitCode
+func (s *supervisor) exited(err error) (exitCode uint32, done bool) {
+	s.running = false
+	s.stopProbes()
+	if cleanupErr := s.proc.Cleanup(); cleanupErr != nil {
+		s.logf("Failed to kill descendants of exited process: %s\n", cleanupErr.Error())
+	}
+	s.cfg.Hooks.Run(hook.StagePostStop, s.cfg.events)
+	lastExit := &ExitReport{Code: restart.ExitCode(err), Time: s.w.clock.Now()}
+	if err != nil {
+		lastExit.Error = err.Error()
+	}
+	s.track(func(c *ChildReport) {
+		c.State, c.PID, c.LastExit = ChildStopped, 0, lastExit
+	})
+
+	switch s.scheduler.Decide(err) {
+	case restart.ActionStop:
+		s.logf("Process exited with code %d, stopping\n", restart.ExitCode(err))
+		return 0, true
+	case restart.ActionFail:
+		s.logf("Process exited with error: %s, restart is not allowed, stopping\n", err.Error())
+		s.failed()
+		return ExitCodeChildFailed, true
+	case restart.ActionRestart:
+		s.logf("Process exited with code %d, restarting in %s\n", restart.ExitCode(err), s.cfg.RestartPolicy.CleanExitDelay)
+		s.restartIn(0, s.cfg.RestartPolicy.CleanExitDelay)
+		return 0, false
+	default:
+		return s.retry(fmt.Sprintf("Process exited with error: %s", err.Error()))
+	}
+}
+
+// retry schedules a restart of the failed child process with backoff,
+// done is set when the supervision must end with the service-specific exitCode
+func (s *supervisor) retry(reason string) (exitCode uint32, done bool) {
+	if s.breaker.Record() {
+		s.logf("%s, crash loop detected: %d restarts within %s, giving up\n", reason, s.breaker.Restarts(), s.cfg.CrashLoop.Window)
+		s.failed()
+		return ExitCodeCrashLoop, true
+	}
+	delay, ok := s.scheduler.Next()
+	if !ok {
+		s.logf("%s, giving up after %d restart attempts\n", reason, s.scheduler.Attempts())
+		s.failed()
+		return ExitCodeChildFailed, true
+	}
+	s.logf("%s, attempting restart in %s\n", reason, delay)
+	s.restartIn(s.scheduler.Attempts(), delay)
+
+	return 0, false
+}
+
+// restartIn schedules the restart of the child process after delay, attempt is the number of the restart attempt
+func (s *supervisor) restartIn(attempt int, delay time.Duration) {
+	s.restartTimer = s.w.clock.After(delay)
+	backoff := &BackoffReport{Attempt: attempt, DelayMs: delay.Milliseconds(), RestartAt: s.w.clock.Now().Add(delay)}
+	s.track(func(c *ChildReport) {
+		c.State, c.Backoff = ChildRestarting, backoff
+	})
+}
+
+// failed records that the child process may not be restarted
+func (s *supervisor) failed() {
+	s.track(func(c *ChildReport) {
+		c.State, c.PID, c.Backoff = ChildFailed, 0, nil
+	})
+}
+
+func (s *supervisor) startProcess(stdout io.Writer) error {
+	environ, overrides, err := s.cfg.Env.Resolve(s.cfg.WorkDir)
+	if err != nil {
+		return errors.Wrap(ErrFailedToStartService, err.Error())
+	}
+	s.logf("Debug: starting process %s in %s, inherit environment: %t, environment: %v\n",
+		s.cfg.ExecPath, s.cfg.WorkDir, s.cfg.Env.Inherit, child.MaskEnv(overrides))
+
+	cmd := exec.Command(s.cfg.ExecPath, s.cfg.ExecArgs...)
+	cmd.Dir = s.cfg.WorkDir
+	cmd.Env = environ
+	cmd.Stdout = stdout
+	cmd.Stderr = s.cfg.output
+	p, err := child.Start(cmd, !s.cfg.StopSequence.ChildOnly)
+	if err != nil {
+		return errors.Wrap(ErrFailedToStartService, err.Error())
+	}
+	s.proc = p
+
+	// every process gets its own channel, the exit of a process Stop gave up on is never taken for a later one
+	exited := make(chan error, 1)
+	s.processExited = exited
+	go func() {
+		exited <- p.Wait()
+	}()
+
+	return nil
+}
+
+func (s *supervisor) stopProcess() error {
+	if err := child.Stop(s.proc, s.processExited, s.cfg.StopSequence, s.w.clock, s.cfg.events); err != nil {
+		return errors.Wrap(ErrFailedToStopService, err.Error())
+	}
+
+	return nil
+}
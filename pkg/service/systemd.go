@@ -0,0 +1,350 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/install"
+)
+
+const (
+	defaultUnitDir   = "/etc/systemd/system"
+	defaultSystemctl = "systemctl"
+)
+
+// systemdManager is the Manager of systemd, services are installed as unit files running the supervisor
+type systemdManager struct {
+	unitDir   string
+	systemctl string
+}
+
+// NewSystemdManager returns the Manager of systemd configured by cfg
+func NewSystemdManager(cfg config.Systemd) Manager {
+	m := systemdManager{unitDir: cfg.UnitDir, systemctl: cfg.SystemctlPath}
+	if m.unitDir == "" {
+		m.unitDir = defaultUnitDir
+	}
+	if m.systemctl == "" {
+		m.systemctl = defaultSystemctl
+	}
+
+	return m
+}
+
+// UnitName returns the name of the systemd unit of the service name, characters not allowed in unit names are replaced with "-"
+func UnitName(name string) string {
+	unit := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(":_.-", r) {
+			return r
+		}
+		return '-'
+	}, name)
+
+	return unit + ".service"
+}
+
+// settingsKey is the unit setting keeping the install settings of the service, systemd ignores settings prefixed with X-
+const settingsKey = "X-InstallSettings="
+
+// RenderUnit renders the systemd unit running cfg.ParentExecPath with args as the supervisor of the service.
+// The restart mode of the restart policy also applies to the supervisor, except for the service-specific exit codes
+// reported once it gave up on a crash loop, a failed critical child or an invalid config.
+// Named accounts run the service as their user, dependencies on groups of Windows services and recovery actions are left out.
+func RenderUnit(cfg config.WindowsServiceConfig, args ...string) (string, error) {
+	opts, err := install.NewOptions(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return renderUnit(cfg, opts, args...)
+}
+
+// renderUnit renders the unit of RenderUnit with the install options opts parsed from cfg
+func renderUnit(cfg config.WindowsServiceConfig, opts install.Options, args ...string) (string, error) {
+	settingsJSON, err := json.Marshal(opts.Settings(cfg, args...))
+	if err != nil {
+		return "", err
+	}
+	var deps []string
+	for _, dep := range opts.Dependencies {
+		if !strings.HasPrefix(dep, "+") {
+			deps = append(deps, UnitName(dep))
+		}
+	}
+
+	restart := "on-failure"
+	switch cfg.RestartPolicy.Mode {
+	case "always":
+		restart = "always"
+	case "never":
+		restart = "no"
+	}
+	restartSec := (cfg.RestartPolicy.InitialDelayMs + 999) / 1000
+	if restartSec == 0 {
+		restartSec = 1
+	}
+
+	command := []string{systemdQuote(cfg.ParentExecPath)}
+	for _, arg := range args {
+		command = append(command, systemdQuote(arg))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", systemdEscapeSpecifiers(cfg.Description))
+	fmt.Fprintf(&b, "After=%s\n", strings.Join(append([]string{"network.target"}, deps...), " "))
+	if len(deps) > 0 {
+		fmt.Fprintf(&b, "Requires=%s\n", strings.Join(deps, " "))
+	}
+	fmt.Fprintf(&b, "%s%s\n", settingsKey, systemdEscape(string(settingsJSON)))
+	fmt.Fprintf(&b, "\n[Service]\n")
+	fmt.Fprintf(&b, "Type=simple\n")
+	if user := opts.User(); user != "" {
+		fmt.Fprintf(&b, "User=%s\n", systemdQuote(user))
+	}
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdQuote(filepath.Dir(cfg.ParentExecPath)))
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(command, " "))
+	fmt.Fprintf(&b, "ExecReload=/bin/kill -HUP $MAINPID\n")
+	fmt.Fprintf(&b, "Restart=%s\n", restart)
+	fmt.Fprintf(&b, "RestartSec=%d\n", restartSec)
+	// the supervisor already gave up on these, restarting it would defeat the crash loop breaker
+	fmt.Fprintf(&b, "RestartPreventExitStatus=%d %d %d\n", ExitCodeCrashLoop, ExitCodeChildFailed, ExitCodeInvalidConfig)
+	// the supervisor stops the children itself, whatever is left is killed once the stop times out
+	fmt.Fprintf(&b, "KillMode=mixed\n")
+	fmt.Fprintf(&b, "\n[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+
+	return b.String(), nil
+}
+
+// systemdEscape escapes the specifiers and variables systemd expands in unit settings
+func systemdEscape(s string) string {
+	return strings.NewReplacer("%", "%%", "$", "$$", "\n", " ").Replace(s)
+}
+
+// systemdEscapeSpecifiers escapes the specifiers systemd expands in settings without variables, such as Description
+func systemdEscapeSpecifiers(s string) string {
+	return strings.NewReplacer("%", "%%", "\n", " ").Replace(s)
+}
+
+// systemdUnescape reverts systemdEscape
+func systemdUnescape(s string) string {
+	return strings.NewReplacer("%%", "%", "$$", "$").Replace(s)
+}
+
+// systemdQuote quotes a command line argument of a unit setting
+func systemdQuote(s string) string {
+	s = systemdEscape(s)
+	if s != "" && !strings.ContainsAny(s, " \t\"';\\") {
+		return s
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (m systemdManager) unitPath(name string) string {
+	return filepath.Join(m.unitDir, UnitName(name))
+}
+
+// installed returns ErrServiceNotExist for op if the unit file of the service name does not exist
+func (m systemdManager) installed(op Operation, name string) error {
+	_, err := os.Stat(m.unitPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return opError(op, name, ErrServiceNotExist, err)
+	}
+	if err != nil {
+		return opError(op, name, ErrFailedToOpenService, err)
+	}
+
+	return nil
+}
+
+// run runs systemctl with args and returns its output
+func (m systemdManager) run(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(m.systemctl, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "Access denied") || strings.Contains(msg, "authentication required") {
+			// systemctl run by a user without the right to manage units
+			err = os.ErrPermission
+		}
+		return "", errors.Wrapf(err, "%s %s: %s", m.systemctl, strings.Join(args, " "), msg)
+	}
+
+	return stdout.String(), nil
+}
+
+func (m systemdManager) Install(cfg config.WindowsServiceConfig, args ...string) error {
+	opts, err := install.NewOptions(cfg)
+	if err != nil {
+		return opError(OpInstall, cfg.Name, ErrFailedToCreateService, err)
+	}
+	unit, err := renderUnit(cfg, opts, args...)
+	if err != nil {
+		return opError(OpInstall, cfg.Name, ErrFailedToCreateService, err)
+	}
+
+	path := m.unitPath(cfg.Name)
+	if _, err := os.Stat(path); err == nil {
+		return opError(OpInstall, cfg.Name, ErrServiceAlreadyExist, nil)
+	}
+
+	if err := os.WriteFile(path, []byte(unit), 0o644); err != nil {
+		return opError(OpInstall, cfg.Name, ErrFailedToCreateService, err)
+	}
+	if _, err := m.run("daemon-reload"); err != nil {
+		os.Remove(path)
+		return opError(OpInstall, cfg.Name, ErrFailedToCreateService, err)
+	}
+	// only automatically started services are enabled, systemd has no delayed or disabled start
+	if opts.StartType == install.StartAutomatic {
+		if _, err := m.run("enable", UnitName(cfg.Name)); err != nil {
+			return opError(OpInstall, cfg.Name, ErrFailedToCreateService, err)
+		}
+	}
+
+	return nil
+}
+
+// Settings returns the install settings kept in the unit file of the service name
+func (m systemdManager) Settings(name string) (install.Settings, error) {
+	if err := m.installed(OpSettings, name); err != nil {
+		return install.Settings{}, err
+	}
+	unit, err := os.ReadFile(m.unitPath(name))
+	if err != nil {
+		return install.Settings{}, opError(OpSettings, name, ErrFailedToReadServiceConfig, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(unit))
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), settingsKey)
+		if !ok {
+			continue
+		}
+		var settings install.Settings
+		if err := json.Unmarshal([]byte(systemdUnescape(value)), &settings); err != nil {
+			return install.Settings{}, opError(OpSettings, name, ErrFailedToReadServiceConfig, errors.Wrap(err, "parse install settings"))
+		}
+		return settings, nil
+	}
+
+	// units installed before the settings were kept in the unit file are reported as empty and fully rewritten
+	return install.Settings{}, nil
+}
+
+// Reconfigure rewrites the unit file of the service and enables it according to its start type
+func (m systemdManager) Reconfigure(cfg config.WindowsServiceConfig, args ...string) error {
+	if err := m.installed(OpReconfigure, cfg.Name); err != nil {
+		return err
+	}
+	opts, err := install.NewOptions(cfg)
+	if err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, err)
+	}
+	unit, err := renderUnit(cfg, opts, args...)
+	if err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, err)
+	}
+
+	path := m.unitPath(cfg.Name)
+	if err := os.WriteFile(path, []byte(unit), 0o644); err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, err)
+	}
+	if _, err := m.run("daemon-reload"); err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, err)
+	}
+	enable := "disable"
+	if opts.StartType == install.StartAutomatic {
+		enable = "enable"
+	}
+	if _, err := m.run(enable, UnitName(cfg.Name)); err != nil {
+		return opError(OpReconfigure, cfg.Name, ErrFailedToUpdateService, err)
+	}
+
+	return nil
+}
+
+func (m systemdManager) Start(name string) error {
+	if err := m.installed(OpStart, name); err != nil {
+		return err
+	}
+	if _, err := m.run("start", "--no-block", UnitName(name)); err != nil {
+		return opError(OpStart, name, ErrFailedToStartService, err)
+	}
+
+	return nil
+}
+
+func (m systemdManager) Stop(name string) error {
+	if err := m.installed(OpStop, name); err != nil {
+		return err
+	}
+	if _, err := m.run("stop", "--no-block", UnitName(name)); err != nil {
+		return opError(OpStop, name, ErrFailedToSendStop, err)
+	}
+
+	return nil
+}
+
+func (m systemdManager) Reload(name string) error {
+	if err := m.installed(OpReload, name); err != nil {
+		return err
+	}
+	if _, err := m.run("reload", UnitName(name)); err != nil {
+		return opError(OpReload, name, ErrFailedToSendReload, err)
+	}
+
+	return nil
+}
+
+func (m systemdManager) Delete(name string) error {
+	if err := m.installed(OpDelete, name); err != nil {
+		return err
+	}
+	// disabling a unit which is not enabled fails harmlessly
+	m.run("disable", UnitName(name))
+	if err := os.Remove(m.unitPath(name)); err != nil {
+		return opError(OpDelete, name, ErrFailedToDeleteService, err)
+	}
+	if _, err := m.run("daemon-reload"); err != nil {
+		return opError(OpDelete, name, ErrFailedToDeleteService, err)
+	}
+
+	return nil
+}
+
+func (m systemdManager) Query(name string) (Status, error) {
+	if err := m.installed(OpQuery, name); err != nil {
+		return Status{}, err
+	}
+	out, err := m.run("show", "--property=ActiveState", "--value", UnitName(name))
+	if err != nil {
+		return Status{}, opError(OpQuery, name, ErrFailedToGetServiceStatus, err)
+	}
+
+	switch state := strings.TrimSpace(out); state {
+	case "active", "reloading":
+		return Status{State: Running}, nil
+	case "activating":
+		return Status{State: StartPending}, nil
+	case "deactivating":
+		return Status{State: StopPending}, nil
+	case "inactive", "failed":
+		return Status{State: Stopped}, nil
+	default:
+		return Status{}, opError(OpQuery, name, ErrFailedToGetServiceStatus, errors.Errorf("unknown state %q", state))
+	}
+}
@@ -0,0 +1,180 @@
+//go:build !windows
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/install"
+)
+
+// newTestSystemd returns a systemd Manager writing units to a temporary directory and running a systemctl stub,
+// the stub records its arguments to the returned file and prints the ActiveState of the state file
+func newTestSystemd(t *testing.T) (m Manager, unitDir, calls, state string) {
+	dir := t.TempDir()
+	unitDir = filepath.Join(dir, "units")
+	require.NoError(t, os.Mkdir(unitDir, 0o755))
+	calls = filepath.Join(dir, "calls")
+	state = filepath.Join(dir, "state")
+	systemctl := filepath.Join(dir, "systemctl")
+	script := "#!/bin/sh\necho \"$*\" >> " + calls + "\nif [ \"$1\" = show ]; then cat " + state + "; fi\n"
+	require.NoError(t, os.WriteFile(systemctl, []byte(script), 0o755))
+
+	return NewSystemdManager(config.Systemd{UnitDir: unitDir, SystemctlPath: systemctl}), unitDir, calls, state
+}
+
+func TestUnitName(t *testing.T) {
+	require.Equal(t, "Example-Windows-Service.service", UnitName("Example Windows Service"))
+	require.Equal(t, "api_v2.service", UnitName("api_v2"))
+}
+
+func TestRenderUnit(t *testing.T) {
+	unit, err := RenderUnit(config.WindowsServiceConfig{
+		Name:           "example",
+		Description:    "Example 100% $service",
+		ParentExecPath: "/opt/example service/service",
+		RestartPolicy:  config.RestartPolicy{Mode: "always", InitialDelayMs: 1500},
+		Account:        `CORP\svc-example`,
+		Dependencies:   []string{"postgresql", "+NetworkProvider"},
+	}, "-config", "/etc/example/service.config.json", "-overlay", "/etc/example/$HOME.json", "run")
+	require.NoError(t, err)
+
+	require.Contains(t, unit, "Description=Example 100%% $service\n")
+	require.Contains(t, unit, `WorkingDirectory="/opt/example service"`+"\n")
+	require.Contains(t, unit, `ExecStart="/opt/example service/service" -config /etc/example/service.config.json -overlay /etc/example/$$HOME.json run`+"\n")
+	require.Contains(t, unit, "Restart=always\n")
+	require.Contains(t, unit, "RestartSec=2\n")
+	require.Contains(t, unit, "RestartPreventExitStatus=1 2 4\n")
+	require.Contains(t, unit, "User=svc-example\n")
+	require.Contains(t, unit, "After=network.target postgresql.service\n")
+	require.Contains(t, unit, "Requires=postgresql.service\n")
+
+	unit, err = RenderUnit(config.WindowsServiceConfig{Name: "service", ParentExecPath: "/usr/bin/service"})
+	require.NoError(t, err)
+	require.Contains(t, unit, "ExecStart=/usr/bin/service\n")
+	require.NotContains(t, unit, "User=")
+	require.NotContains(t, unit, "Requires=")
+	require.Contains(t, unit, "Restart=on-failure\n")
+	require.Contains(t, unit, "RestartSec=1\n")
+}
+
+func TestSystemdManager(t *testing.T) {
+	m, unitDir, calls, state := newTestSystemd(t)
+	cfg := config.WindowsServiceConfig{Name: "api", Description: "API", ParentExecPath: "/usr/bin/api", StartType: "automatic"}
+
+	_, err := m.Query("api")
+	require.ErrorIs(t, err, ErrServiceNotExist)
+	require.ErrorIs(t, m.Start("api"), ErrServiceNotExist)
+
+	invalid := cfg
+	invalid.StartType = "later"
+	err = m.Install(invalid, "run")
+	var opErr *OpError
+	require.ErrorAs(t, err, &opErr)
+	require.Equal(t, OpInstall, opErr.Op)
+	require.ErrorIs(t, err, ErrFailedToCreateService)
+	require.ErrorIs(t, err, install.ErrInvalidStartType)
+	require.NoFileExists(t, filepath.Join(unitDir, "api.service"))
+
+	require.NoError(t, m.Install(cfg, "run"))
+	unit, err := os.ReadFile(filepath.Join(unitDir, "api.service"))
+	require.NoError(t, err)
+	require.Contains(t, string(unit), "ExecStart=/usr/bin/api run\n")
+	require.ErrorIs(t, m.Install(cfg), ErrServiceAlreadyExist)
+
+	require.NoError(t, m.Start("api"))
+	for active, want := range map[string]State{
+		"active":       Running,
+		"reloading":    Running,
+		"activating":   StartPending,
+		"deactivating": StopPending,
+		"inactive":     Stopped,
+		"failed":       Stopped,
+	} {
+		require.NoError(t, os.WriteFile(state, []byte(active+"\n"), 0o644))
+		status, err := m.Query("api")
+		require.NoError(t, err)
+		require.Equal(t, want, status.State, active)
+	}
+	require.NoError(t, os.WriteFile(state, []byte("unknown\n"), 0o644))
+	_, err = m.Query("api")
+	require.ErrorIs(t, err, ErrFailedToGetServiceStatus)
+
+	require.NoError(t, m.Reload("api"))
+	require.NoError(t, m.Stop("api"))
+	require.NoError(t, m.Delete("api"))
+	require.NoFileExists(t, filepath.Join(unitDir, "api.service"))
+
+	data, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Equal(t, []string{"daemon-reload", "enable api.service", "start --no-block api.service"}, lines[:3])
+	require.Equal(t, []string{
+		"reload api.service",
+		"stop --no-block api.service",
+		"disable api.service",
+		"daemon-reload",
+	}, lines[len(lines)-4:])
+}
+
+func TestSystemdReconfigure(t *testing.T) {
+	m, unitDir, calls, _ := newTestSystemd(t)
+	cfg := config.WindowsServiceConfig{Name: "api", Description: "100% API", ParentExecPath: "/usr/bin/api", StartType: "automatic"}
+
+	_, err := m.Settings("api")
+	require.ErrorIs(t, err, ErrServiceNotExist)
+	require.ErrorIs(t, m.Reconfigure(cfg), ErrServiceNotExist)
+
+	require.NoError(t, m.Install(cfg, "run"))
+	settings, err := m.Settings("api")
+	require.NoError(t, err)
+	want, err := install.NewSettings(cfg, "run")
+	require.NoError(t, err)
+	require.Equal(t, want, settings)
+
+	cfg.StartType = "manual"
+	cfg.Description = "API $HOME"
+	require.NoError(t, m.Reconfigure(cfg, "run"))
+	settings, err = m.Settings("api")
+	require.NoError(t, err)
+	require.Equal(t, install.StartManual, settings.StartType)
+	require.Equal(t, "API $HOME", settings.Description)
+	unit, err := os.ReadFile(filepath.Join(unitDir, "api.service"))
+	require.NoError(t, err)
+	require.Contains(t, string(unit), "Description=API $HOME\n")
+
+	data, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Equal(t, []string{"daemon-reload", "disable api.service"}, lines[len(lines)-2:])
+
+	// units installed without the install settings are rewritten as a whole
+	require.NoError(t, os.WriteFile(filepath.Join(unitDir, "old.service"), []byte("[Unit]\nDescription=old\n"), 0o644))
+	settings, err = m.Settings("old")
+	require.NoError(t, err)
+	require.Equal(t, install.Settings{}, settings)
+}
+
+func TestSystemdAccessDenied(t *testing.T) {
+	dir := t.TempDir()
+	systemctl := filepath.Join(dir, "systemctl")
+	script := "#!/bin/sh\necho 'Failed to start api.service: Access denied' >&2\nexit 1\n"
+	require.NoError(t, os.WriteFile(systemctl, []byte(script), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "api.service"), nil, 0o644))
+	m := NewSystemdManager(config.Systemd{UnitDir: dir, SystemctlPath: systemctl})
+
+	err := m.Start("api")
+	require.ErrorIs(t, err, ErrFailedToStartService)
+	require.ErrorIs(t, err, ErrAccessDenied)
+	require.Contains(t, err.Error(), "Access denied")
+
+	err = m.Start("missing")
+	require.ErrorIs(t, err, ErrServiceNotExist)
+	require.NotErrorIs(t, err, ErrAccessDenied)
+}
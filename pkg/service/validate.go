@@ -0,0 +1,68 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/edwardezs/win-svc/pkg/child"
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/health"
+	"github.com/edwardezs/win-svc/pkg/hook"
+	"github.com/edwardezs/win-svc/pkg/install"
+	"github.com/edwardezs/win-svc/pkg/restart"
+)
+
+// Validate checks the config with config.Validate and parses the restart, stop, health, hook and install
+// settings the same way the service does, all problems are returned at once as a *config.ValidationError
+func Validate(cfg config.WindowsServiceConfig) error {
+	v := &config.ValidationError{}
+	if err := cfg.Validate(); err != nil && !errors.As(err, &v) {
+		return err
+	}
+	add := func(field string, err error) {
+		if err != nil {
+			v.Errors = append(v.Errors, &config.FieldError{Field: field, Err: err})
+		}
+	}
+
+	if len(cfg.Children) == 0 {
+		checkSettings(add, "", config.Child{
+			RestartPolicy: cfg.RestartPolicy,
+			CrashLoop:     cfg.CrashLoop,
+			Stop:          cfg.Stop,
+			HealthChecks:  cfg.HealthChecks,
+			Readiness:     cfg.Readiness,
+			Hooks:         cfg.Hooks,
+		})
+	}
+	for i, c := range cfg.Children {
+		checkSettings(add, fmt.Sprintf("children[%d].", i), c)
+	}
+
+	v.Errors = append(v.Errors, install.Check(cfg)...)
+
+	if len(v.Errors) > 0 {
+		return v
+	}
+
+	return nil
+}
+
+// checkSettings parses the settings of a child, prefix is the JSON path of the child with a trailing dot
+func checkSettings(add func(field string, err error), prefix string, c config.Child) {
+	_, err := restart.NewPolicy(c.RestartPolicy)
+	add(prefix+"restartPolicy", err)
+	_, err = restart.NewCrashLoopPolicy(c.CrashLoop)
+	add(prefix+"crashLoop", err)
+	_, err = child.NewStopSequence(c.Stop)
+	add(prefix+"stop", err)
+	for i, check := range c.HealthChecks {
+		_, err = health.NewChecks([]config.HealthCheck{check})
+		add(fmt.Sprintf("%shealthChecks[%d]", prefix, i), err)
+	}
+	_, err = health.NewGate(c.Readiness)
+	add(prefix+"readiness", err)
+	_, err = hook.New(c.Hooks)
+	add(prefix+"hooks", err)
+}
@@ -0,0 +1,76 @@
+//go:build !windows
+
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edwardezs/win-svc/pkg/child"
+	"github.com/edwardezs/win-svc/pkg/config"
+	"github.com/edwardezs/win-svc/pkg/health"
+	"github.com/edwardezs/win-svc/pkg/install"
+	"github.com/edwardezs/win-svc/pkg/restart"
+)
+
+func TestValidate(t *testing.T) {
+	cfg := config.WindowsServiceConfig{
+		Name:           "svc",
+		Description:    "test service",
+		ParentExecPath: "/bin/sh",
+		ChildExecPath:  "/bin/sh",
+	}
+	require.NoError(t, Validate(cfg))
+
+	cfg.RestartPolicy.Mode = "sometimes"
+	cfg.HealthChecks = []config.HealthCheck{{Type: "tcp", Address: "localhost:80"}, {Type: "udp"}}
+	cfg.StartType = "later"
+	err := Validate(cfg)
+	var validationErr *config.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+
+	fields := make(map[string]error)
+	for _, fieldErr := range validationErr.Errors {
+		fields[fieldErr.Field] = fieldErr
+	}
+	require.Len(t, fields, 3)
+	require.ErrorIs(t, fields["restartPolicy"], restart.ErrInvalidMode)
+	require.ErrorIs(t, fields["healthChecks[1]"], health.ErrInvalidType)
+	require.ErrorIs(t, fields["startType"], install.ErrInvalidStartType)
+
+	cfg = config.WindowsServiceConfig{
+		Name:           "svc",
+		Description:    "test service",
+		ParentExecPath: "/bin/sh",
+		Children: []config.Child{
+			{Name: "web", ExecPath: "/bin/sh"},
+			{Name: "worker", ExecPath: "/bin/sh", Stop: config.Stop{Signal: "SIGNOPE"}},
+		},
+		Account:      "NetworkService",
+		Password:     "secret",
+		Dependencies: []string{"db", "DB"},
+		Recovery: config.Recovery{Actions: []config.RecoveryAction{
+			{Type: "explode"},
+			{Type: "run-command", DelayMs: -1},
+		}},
+	}
+	cfg.Name = strings.Repeat("s", 300)
+	err = Validate(cfg)
+	require.True(t, errors.As(err, &validationErr))
+
+	fields = make(map[string]error)
+	for _, fieldErr := range validationErr.Errors {
+		fields[fieldErr.Field] = fieldErr
+	}
+	require.Len(t, fields, 7)
+	require.ErrorIs(t, fields["children[1].stop"], child.ErrInvalidSignal)
+	require.ErrorIs(t, fields["name"], install.ErrNameTooLong)
+	require.ErrorIs(t, fields["password"], install.ErrPasswordNotAllowed)
+	require.ErrorIs(t, fields["dependencies[1]"], install.ErrInvalidDependency)
+	require.ErrorIs(t, fields["recovery.actions[0].type"], install.ErrInvalidRecovery)
+	require.ErrorIs(t, fields["recovery.actions[1].delayMs"], install.ErrInvalidRecovery)
+	require.ErrorIs(t, fields["recovery.command"], install.ErrRecoveryCommand)
+}
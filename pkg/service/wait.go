@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is how long operations wait for the service to reach the requested state by default
+const DefaultTimeout = 30 * time.Second
+
+const (
+	waitPollInterval    = 100 * time.Millisecond
+	waitMaxPollInterval = 2 * time.Second
+)
+
+// WaitState polls the Manager with backoff until the service reaches state or ctx is done.
+// Waiting for Running fails with ErrFailedToStartService if the service stops again after it began to start.
+func (w *WindowsService) WaitState(ctx context.Context, state State) error {
+	op := OpStart
+	if state == Stopped {
+		op = OpStop
+	}
+	interval := waitPollInterval
+	pending := false
+	for {
+		status, err := w.Manager.Query(w.Name)
+		if err != nil {
+			return err
+		}
+		if status.State == state {
+			return nil
+		}
+		if state == Running && status.State == Stopped && pending {
+			return opError(op, w.Name, ErrFailedToStartService, errors.New("stopped while starting, see the service log for the reason"))
+		}
+		pending = pending || status.State != Stopped
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return ctx.Err()
+			}
+			cause := errors.Wrapf(ctx.Err(), "state is %s", status.State)
+			if state == Stopped {
+				return opError(op, w.Name, ErrStopTimeoutExceeded, cause)
+			}
+			return opError(op, w.Name, ErrStartTimeoutExceeded, cause)
+		case <-w.clock.After(interval):
+		}
+		interval = min(2*interval, waitMaxPollInterval)
+	}
+}
+
+// waitContext returns ctx bounded by Timeout
+func (w *WindowsService) waitContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
@@ -1,6 +1,9 @@
+//go:build windows
+
 package test
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"os/exec"
@@ -21,40 +24,40 @@ const (
 )
 
 func (s *WindowsServiceTestSuite) TestExecution() {
-	require.NoError(s.T(), s.svc.Install())
+	require.NoError(s.T(), s.svc.Install(context.Background()))
 
-	err := s.svc.Install()
+	err := s.svc.Install(context.Background())
 	require.Error(s.T(), err)
-	require.Equal(s.T(), err, service.ErrServiceAlreadyExist)
+	require.ErrorIs(s.T(), err, service.ErrServiceAlreadyExist)
 	time.Sleep(installDelay)
 
-	require.NoError(s.T(), s.svc.Start())
+	require.NoError(s.T(), s.svc.Start(context.Background()))
 	time.Sleep(startDelay)
 
 	resp, err := http.Get(childURL)
 	require.NoError(s.T(), err)
 	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
 
-	require.NoError(s.T(), s.svc.Stop())
+	require.NoError(s.T(), s.svc.Stop(context.Background()))
 	time.Sleep(stopDelay)
 
 	_, err = http.Get(childURL)
 	require.Error(s.T(), err)
 	require.Contains(s.T(), err.Error(), "connectex: No connection could be made")
 
-	require.NoError(s.T(), s.svc.Delete())
+	require.NoError(s.T(), s.svc.Delete(context.Background()))
 	time.Sleep(deleteDelay)
 
-	err = s.svc.Delete()
+	err = s.svc.Delete(context.Background())
 	require.Error(s.T(), err)
-	require.Equal(s.T(), err, service.ErrServiceNotExist)
+	require.ErrorIs(s.T(), err, service.ErrServiceNotExist)
 }
 
 func (s *WindowsServiceTestSuite) TestChildProcessKill() {
-	require.NoError(s.T(), s.svc.Install())
+	require.NoError(s.T(), s.svc.Install(context.Background()))
 	time.Sleep(installDelay)
 
-	require.NoError(s.T(), s.svc.Start())
+	require.NoError(s.T(), s.svc.Start(context.Background()))
 	time.Sleep(startDelay)
 
 	_, err := os.Stat(logFile)
@@ -76,12 +79,12 @@ func (s *WindowsServiceTestSuite) TestChildProcessKill() {
 	require.NoError(s.T(), err)
 	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
 
-	require.NoError(s.T(), s.svc.Stop())
+	require.NoError(s.T(), s.svc.Stop(context.Background()))
 	time.Sleep(stopDelay)
 
 	content, err = os.ReadFile(logFile)
 	require.NoError(s.T(), err)
 	require.Contains(s.T(), string(content), "Process stopped")
 
-	require.NoError(s.T(), s.svc.Delete())
+	require.NoError(s.T(), s.svc.Delete(context.Background()))
 }